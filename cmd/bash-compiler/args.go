@@ -5,13 +5,19 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/alecthomas/kong"
 	"github.com/fchastanet/bash-compiler/internal/utils/files"
 )
 
-const constMaxScreenSize = 80
+const (
+	constMaxScreenSize = 80
+	version            = "3.2.0"
+)
+
+var commandsWithoutConfigFile = []string{"init", "export-templates", "locate"}
 
 type rootDirError struct {
 	error
@@ -38,13 +44,73 @@ func (*rootDirOptionShouldNotBeProvidedError) Error() string {
 }
 
 type cli struct {
-	YamlFiles            YamlFiles            `arg:""    optional:"" type:"path"                help:"Yaml files"`                                                            //nolint:tagalign //avoid reformat annotations
+	Compile              compileCmd           `cmd:""    default:"withargs"                    help:"Compile the binary files (default command)"`                             //nolint:tagalign //avoid reformat annotations
+	Deps                 depsCmd              `cmd:""                                          help:"Export the graph of the functions included in the binary files"`         //nolint:tagalign //avoid reformat annotations
+	Which                whichCmd             `cmd:""                                          help:"Display the file used for a function and its shadowed copies"`           //nolint:tagalign //avoid reformat annotations
+	Init                 initCmd              `cmd:""                                          help:"Create a new project with a sample binary model"`                        //nolint:tagalign //avoid reformat annotations
+	NewBinary            newBinaryCmd         `cmd:""    name:"new-binary"                     help:"Add a binary model to the current project"`                              //nolint:tagalign //avoid reformat annotations
+	ExportTemplates      exportTemplatesCmd   `cmd:""    name:"export-templates"               help:"Write the default templates in a directory to customize them"`           //nolint:tagalign //avoid reformat annotations
+	Locate               locateCmd            `cmd:""                                          help:"Display the source file of a line of a compiled binary"`                 //nolint:tagalign //avoid reformat annotations
+	Doc                  docCmd               `cmd:""                                          help:"Generate the reference documentation of the framework functions"`        //nolint:tagalign //avoid reformat annotations
 	RootDirectory        RootDirectory        `short:"r" optional:"" type:"path" name:"rootDir" help:"Root directory containing binary files"`                                //nolint:tagalign //avoid reformat annotations
 	IntermediateFilesDir IntermediateFilesDir `short:"t" optional:""                            help:"Directory that will contain generated files (no save if not provided)"` //nolint:tagalign //avoid reformat annotations
 	BinaryFilesExtension BinaryFilesExtension `          optional:"" default:"-binary.yaml"     help:"Provide the extension for automatic search of binary files"`            //nolint:tagalign //avoid reformat annotations
 	Version              VersionFlag          `short:"v" name:"version"                         help:"Print version information and quit"`                                    //nolint:tagalign //avoid reformat annotations
 	Debug                bool                 `short:"d"                                        help:"Set log in debug level"`                                                //nolint:tagalign //avoid reformat annotations
 	LogLevel             int                  `hidden:""`
+	// Command is the name of the selected command
+	Command string `kong:"-"`
+}
+
+type compileCmd struct {
+	YamlFiles      YamlFiles `arg:""    optional:"" type:"path"                  help:"Yaml files"`                                                            //nolint:tagalign //avoid reformat annotations
+	Watch          bool      `short:"w" xor:"check,diff,diffFile,report"         help:"Watch files and recompile impacted binaries on change"`                 //nolint:tagalign //avoid reformat annotations
+	Check          bool      `          xor:"check"                              help:"Write nothing, fail if a binary file is not up to date"`                //nolint:tagalign //avoid reformat annotations
+	Diff           bool      `          xor:"diff"                               help:"Write nothing, display the diff of each binary file that would change"` //nolint:tagalign //avoid reformat annotations
+	DiffFile       string    `          xor:"diffFile" type:"path"               help:"Write the diff in this patch file (implies --diff)"`                    //nolint:tagalign //avoid reformat annotations
+	Jobs           int       `short:"j"                default:"1"               help:"Number of binary models compiled in parallel (0 for number of CPUs)"`   //nolint:tagalign //avoid reformat annotations
+	Report         string    `          xor:"report"   type:"path"               help:"Write a json report of the compilation in this file"`                   //nolint:tagalign //avoid reformat annotations
+	CacheDir       string    `          type:"path"    placeholder:"DIR"         help:"Cache directory used to skip binaries whose inputs are unchanged"`      //nolint:tagalign //avoid reformat annotations
+	Release        bool      `                                                   help:"Remove comments and blank lines from the binary files"`                 //nolint:tagalign //avoid reformat annotations
+	DenyDeprecated bool      `                                                   help:"Fail if a binary file includes a deprecated function"`                  //nolint:tagalign //avoid reformat annotations
+	Worker         bool      `hidden:""`
+}
+
+type depsCmd struct {
+	YamlFiles YamlFiles `arg:""    optional:"" type:"path"                  help:"Yaml files"`                                                      //nolint:tagalign //avoid reformat annotations
+	Format    string    `short:"f" enum:"dot,mermaid,json" default:"dot"    help:"Graph format (dot, mermaid or json)"`                             //nolint:tagalign //avoid reformat annotations
+	Why       string    `          placeholder:"FUNCTION"                   help:"Display the chain of references including this function instead"` //nolint:tagalign //avoid reformat annotations
+	Output    string    `short:"o" type:"path"                              help:"Write the graph in this file instead of stdout"`                  //nolint:tagalign //avoid reformat annotations
+}
+
+type whichCmd struct {
+	FunctionName string    `arg:""    help:"Framework function name (eg: Log::displayInfo)"` //nolint:tagalign //avoid reformat annotations
+	YamlFiles    YamlFiles `arg:""    optional:"" type:"path" help:"Yaml files"`             //nolint:tagalign //avoid reformat annotations
+}
+
+type initCmd struct {
+	BinaryName      string `arg:""    optional:"" default:"hello" help:"Name of the sample binary"`                                                //nolint:tagalign //avoid reformat annotations
+	FrameworkSrcDir string `          placeholder:"DIR"            help:"bash-tools-framework src directory added to srcDirs of the binary model"` //nolint:tagalign //avoid reformat annotations
+}
+
+type newBinaryCmd struct {
+	BinaryName      string `arg:""                                help:"Name of the binary"`                                                       //nolint:tagalign //avoid reformat annotations
+	FrameworkSrcDir string `          placeholder:"DIR"            help:"bash-tools-framework src directory added to srcDirs of the binary model"` //nolint:tagalign //avoid reformat annotations
+}
+
+type exportTemplatesCmd struct {
+	TargetDir string `arg:""    type:"path"                    help:"Directory in which the default templates are written"` //nolint:tagalign //avoid reformat annotations
+	Force     bool   `short:"f"                                help:"Overwrite existing files"`                             //nolint:tagalign //avoid reformat annotations
+}
+
+type locateCmd struct {
+	Location string `arg:"" placeholder:"BINARY:LINE" help:"Line of the compiled binary (eg: bin/myBinary:42)"` //nolint:tagalign //avoid reformat annotations
+}
+
+type docCmd struct {
+	YamlFiles YamlFiles `arg:""    optional:"" type:"path"                  help:"Yaml files"`                                             //nolint:tagalign //avoid reformat annotations
+	Output    string    `short:"o" required:"" type:"path"                  help:"Directory in which the documentation pages are written"` //nolint:tagalign //avoid reformat annotations
+	Format    string    `short:"f" enum:"markdown,html" default:"markdown"  help:"Documentation format (markdown or html)"`                //nolint:tagalign //avoid reformat annotations
 }
 
 type (
@@ -108,7 +174,7 @@ func (intermediateFilesDir *IntermediateFilesDir) Validate(
 
 func parseArgs(cli *cli) (err error) {
 	// just need the yaml file, from which all the dependencies will be deduced
-	kong.Parse(cli,
+	ctx := kong.Parse(cli,
 		kong.Name("bash-compiler"),
 		kong.Description("From a yaml file describing the bash application, "+
 			"interprets the templates and import the necessary bash functions"),
@@ -125,10 +191,12 @@ func parseArgs(cli *cli) (err error) {
 			ValueFormatter:      kong.DefaultHelpValueFormatter,
 		}),
 		kong.Vars{
-			"version": "3.2.0",
+			"version": version,
 		},
 	)
 
+	cli.Command = strings.Fields(ctx.Command())[0]
+
 	currentDir, err := os.Getwd()
 	if err != nil {
 		return err
@@ -144,7 +212,8 @@ func parseArgs(cli *cli) (err error) {
 		cli.RootDirectory = RootDirectory(currentDir)
 	}
 	bashCompilerFile := filepath.Join(string(cli.RootDirectory), ".bash-compiler")
-	if _, err = os.Stat(bashCompilerFile); err != nil {
+	// init command creates the .bash-compiler file, export-templates and locate do not need it
+	if _, err = os.Stat(bashCompilerFile); err != nil && !slices.Contains(commandsWithoutConfigFile, cli.Command) {
 		slog.Error("current directory should contain file .bash-compiler", "expectedFile", bashCompilerFile)
 		return &missingBashCompilerFileError{err}
 	}
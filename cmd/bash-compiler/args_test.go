@@ -15,13 +15,23 @@ func getDefaultExpectedCli(expectedCli *cli) error {
 	if err != nil {
 		return err
 	}
-	expectedCli.YamlFiles = YamlFiles(expectedYamlFiles)
+	expectedCli.Compile.YamlFiles = YamlFiles(expectedYamlFiles)
+	expectedCli.Command = "compile"
 
 	expectedCli.RootDirectory = RootDirectory(currentDir)
 	expectedCli.BinaryFilesExtension = BinaryFilesExtension("-binary.yaml")
 	expectedCli.Version = VersionFlag("")
 	expectedCli.IntermediateFilesDir = IntermediateFilesDir("")
 	expectedCli.Debug = false
+	expectedCli.Compile.Watch = false
+	expectedCli.Compile.Check = false
+	expectedCli.Compile.Diff = false
+	expectedCli.Compile.DiffFile = ""
+	expectedCli.Compile.Jobs = 1
+	expectedCli.Compile.Worker = false
+	expectedCli.Deps.Format = "dot"
+	expectedCli.Doc.Format = "markdown"
+	expectedCli.Init.BinaryName = "hello"
 	expectedCli.LogLevel = int(slog.LevelInfo)
 	return nil
 }
@@ -97,8 +107,8 @@ func TestArgs(t *testing.T) {
 		expectedCli := &cli{} //nolint:exhaustruct //test
 		err := getDefaultExpectedCli(expectedCli)
 		assert.NilError(t, err)
-		expectedCli.YamlFiles = append(
-			expectedCli.YamlFiles,
+		expectedCli.Compile.YamlFiles = append(
+			expectedCli.Compile.YamlFiles,
 			filepath.Join(string(expectedCli.RootDirectory), "file-binary.yaml"),
 		)
 		cli := &cli{} //nolint:exhaustruct //test
@@ -107,6 +117,90 @@ func TestArgs(t *testing.T) {
 		assert.DeepEqual(t, expectedCli, cli)
 	})
 
+	t.Run("deps command", func(t *testing.T) {
+		os.Args = []string{"cmd", "deps", "file-binary.yaml", "--format", "mermaid", "--why", "Log::info"}
+		expectedCli := &cli{} //nolint:exhaustruct //test
+		err := getDefaultExpectedCli(expectedCli)
+		assert.NilError(t, err)
+		expectedCli.Command = "deps"
+		expectedCli.Deps.YamlFiles = YamlFiles{
+			filepath.Join(string(expectedCli.RootDirectory), "file-binary.yaml"),
+		}
+		expectedCli.Deps.Format = "mermaid"
+		expectedCli.Deps.Why = "Log::info"
+		cli := &cli{} //nolint:exhaustruct //test
+		err = parseArgs(cli)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, expectedCli, cli)
+	})
+
+	t.Run("which command", func(t *testing.T) {
+		os.Args = []string{"cmd", "which", "Log::info", "file-binary.yaml"}
+		expectedCli := &cli{} //nolint:exhaustruct //test
+		err := getDefaultExpectedCli(expectedCli)
+		assert.NilError(t, err)
+		expectedCli.Command = "which"
+		expectedCli.Which.FunctionName = "Log::info"
+		expectedCli.Which.YamlFiles = YamlFiles{
+			filepath.Join(string(expectedCli.RootDirectory), "file-binary.yaml"),
+		}
+		cli := &cli{} //nolint:exhaustruct //test
+		err = parseArgs(cli)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, expectedCli, cli)
+	})
+
+	t.Run("export-templates command", func(t *testing.T) {
+		os.Args = []string{"cmd", "export-templates", "myTemplates", "--force"}
+		expectedCli := &cli{} //nolint:exhaustruct //test
+		err := getDefaultExpectedCli(expectedCli)
+		assert.NilError(t, err)
+		expectedCli.Command = "export-templates"
+		expectedCli.ExportTemplates.TargetDir = filepath.Join(string(expectedCli.RootDirectory), "myTemplates")
+		expectedCli.ExportTemplates.Force = true
+		cli := &cli{} //nolint:exhaustruct //test
+		err = parseArgs(cli)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, expectedCli, cli)
+	})
+
+	t.Run("locate command", func(t *testing.T) {
+		os.Args = []string{"cmd", "locate", "bin/myBinary:42"}
+		expectedCli := &cli{} //nolint:exhaustruct //test
+		err := getDefaultExpectedCli(expectedCli)
+		assert.NilError(t, err)
+		expectedCli.Command = "locate"
+		expectedCli.Locate.Location = "bin/myBinary:42"
+		cli := &cli{} //nolint:exhaustruct //test
+		err = parseArgs(cli)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, expectedCli, cli)
+
+		binaryFile, line, err := parseBinaryLocation(cli.Locate.Location)
+		assert.NilError(t, err)
+		assert.Equal(t, "bin/myBinary", binaryFile)
+		assert.Equal(t, 42, line)
+		_, _, err = parseBinaryLocation("bin/myBinary")
+		assert.Error(t, err, "invalid location 'bin/myBinary', expected <binary>:<line>")
+	})
+
+	t.Run("doc command", func(t *testing.T) {
+		os.Args = []string{"cmd", "doc", "file-binary.yaml", "-o", "doc", "--format", "html"}
+		expectedCli := &cli{} //nolint:exhaustruct //test
+		err := getDefaultExpectedCli(expectedCli)
+		assert.NilError(t, err)
+		expectedCli.Command = "doc"
+		expectedCli.Doc.YamlFiles = YamlFiles{
+			filepath.Join(string(expectedCli.RootDirectory), "file-binary.yaml"),
+		}
+		expectedCli.Doc.Output = filepath.Join(string(expectedCli.RootDirectory), "doc")
+		expectedCli.Doc.Format = "html"
+		cli := &cli{} //nolint:exhaustruct //test
+		err = parseArgs(cli)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, expectedCli, cli)
+	})
+
 	err = os.Chdir(currentDir)
 	assert.NilError(t, err)
 }
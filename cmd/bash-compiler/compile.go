@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/fchastanet/bash-compiler/internal/services"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+func runCompile(cli *cli) {
+	mountDefaultTemplates()
+
+	var buildCache *services.BuildCache
+	if cli.Compile.CacheDir != "" {
+		buildCache = services.NewBuildCache(cli.Compile.CacheDir, version, cli.Compile.Release)
+	}
+	compilerPipelineService := newCompilerPipelineService(
+		cli,
+		cli.Compile.YamlFiles,
+		services.CompilerPipelineOptions{ //nolint:exhaustruct // common options set by newCompilerPipelineService
+			Check:                cli.Compile.Check,
+			Diff:                 cli.Compile.Diff,
+			DiffFile:             cli.Compile.DiffFile,
+			Jobs:                 cli.Compile.Jobs,
+			ReportFile:           cli.Compile.Report,
+			Release:              cli.Compile.Release,
+			DenyDeprecated:       cli.Compile.DenyDeprecated,
+			BuildCache:           buildCache,
+			WorkerCommandFactory: newWorkerCommandFactory(cli),
+		},
+	)
+	if cli.Compile.Worker {
+		err := compilerPipelineService.ProcessWorker(os.Stdout)
+		logger.Check(err)
+		return
+	}
+	if cli.Compile.Watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err := compilerPipelineService.Watch(ctx)
+		logger.Check(err)
+		return
+	}
+	err := compilerPipelineService.ProcessPipeline()
+	logger.Check(err)
+}
+
+// newWorkerCommandFactory creates the commands allowing to compile
+// a binary model using a new bash-compiler process
+func newWorkerCommandFactory(cli *cli) services.WorkerCommandFactory {
+	executable, err := os.Executable()
+	logger.Check(err)
+	return func(binaryModelFilePath string) *exec.Cmd {
+		args := []string{"compile", binaryModelFilePath, "--jobs=1", "--worker"}
+		if isUsingGoRun() {
+			args = append(args, "--rootDir", string(cli.RootDirectory))
+		}
+		if cli.IntermediateFilesDir != "" {
+			args = append(args, "--intermediate-files-dir", string(cli.IntermediateFilesDir))
+		}
+		if cli.Debug {
+			args = append(args, "--debug")
+		}
+		if cli.Compile.CacheDir != "" {
+			args = append(args, "--cache-dir", cli.Compile.CacheDir)
+		}
+		if cli.Compile.Release {
+			args = append(args, "--release")
+		}
+		if cli.Compile.DenyDeprecated {
+			args = append(args, "--deny-deprecated")
+		}
+		if cli.Compile.Check {
+			args = append(args, "--check")
+		}
+		if cli.Compile.Diff || cli.Compile.DiffFile != "" {
+			// diff file is written by the main process
+			args = append(args, "--diff")
+		}
+		cmd := exec.Command(executable, args...)
+		cmd.Dir = string(cli.RootDirectory)
+		return cmd
+	}
+}
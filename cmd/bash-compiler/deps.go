@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/fchastanet/bash-compiler/internal/compiler"
+	"github.com/fchastanet/bash-compiler/internal/services"
+	"github.com/fchastanet/bash-compiler/internal/utils/customerrors"
+	"github.com/fchastanet/bash-compiler/internal/utils/files"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+func runDeps(cli *cli) {
+	mountDefaultTemplates()
+	compilerPipelineService := newCompilerPipelineService(
+		cli, cli.Deps.YamlFiles, services.CompilerPipelineOptions{}, //nolint:exhaustruct // no compilation
+	)
+	graphs, err := compilerPipelineService.ComputeDependencyGraphs()
+	logger.Check(err)
+
+	if cli.Deps.Output == "" {
+		err = writeDeps(os.Stdout, cli, graphs)
+	} else {
+		err = writeDepsFile(cli.Deps.Output, cli, graphs)
+	}
+	logger.Check(err)
+}
+
+// writeDepsFile writes the graphs in outputFile, the error of the file close
+// is reported as writes can be deferred until then
+func writeDepsFile(outputFile string, cli *cli, graphs []*compiler.DependencyGraph) (err error) {
+	output, err := os.OpenFile(outputFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, files.AllReadPerm)
+	if err != nil {
+		return err
+	}
+	defer customerrors.SafeCloseDeferCallback(output, &err)
+	return writeDeps(output, cli, graphs)
+}
+
+func writeDeps(output io.Writer, cli *cli, graphs []*compiler.DependencyGraph) error {
+	if cli.Deps.Why != "" {
+		return services.WriteReferenceChains(output, graphs, cli.Deps.Why)
+	}
+	return compiler.WriteDependencyGraphs(output, cli.Deps.Format, graphs)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/fchastanet/bash-compiler/internal/services"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+func runDoc(cli *cli) {
+	mountDefaultTemplates()
+	compilerPipelineService := newCompilerPipelineService(
+		cli, cli.Doc.YamlFiles, services.CompilerPipelineOptions{}, //nolint:exhaustruct // no compilation
+	)
+	documentation, err := compilerPipelineService.ComputeDocumentation()
+	logger.Check(err)
+	_, err = services.WriteDocumentation(cli.Doc.Output, cli.Doc.Format, documentation)
+	logger.Check(err)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/files"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+type templateFileAlreadyExistsError struct {
+	error
+	FilePath string
+}
+
+func (err *templateFileAlreadyExistsError) Error() string {
+	return "file already exists (use --force to overwrite it): " + err.FilePath
+}
+
+func runExportTemplates(cli *cli) {
+	targetDir := cli.ExportTemplates.TargetDir
+	if !cli.ExportTemplates.Force {
+		err := checkNoTemplateFileExists(targetDir)
+		logger.Check(err)
+	}
+	err := os.MkdirAll(targetDir, files.AllReadExecutePerm)
+	logger.Check(err)
+	err = files.CopyEmbeddedFiles(templateFs, defaultTemplatesDir, targetDir)
+	logger.Check(err)
+	slog.Info(
+		"Default templates exported, add this directory to templateDirs to override them",
+		logger.LogFieldDirPath, targetDir,
+	)
+}
+
+// checkNoTemplateFileExists ensures that the export does not overwrite any file
+func checkNoTemplateFileExists(targetDir string) error {
+	return fs.WalkDir(templateFs, defaultTemplatesDir, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil || dirEntry.IsDir() {
+			return err
+		}
+		relativePath, err := filepath.Rel(defaultTemplatesDir, path)
+		if err != nil {
+			return err
+		}
+		filePath := filepath.Join(targetDir, relativePath)
+		_, err = os.Stat(filePath)
+		if err == nil {
+			return &templateFileAlreadyExistsError{nil, filePath}
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/fchastanet/bash-compiler/internal/services"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+func runInit(cli *cli) {
+	projectScaffoldService := services.NewProjectScaffoldService(
+		string(cli.RootDirectory), string(cli.BinaryFilesExtension), cli.Init.FrameworkSrcDir,
+	)
+	createdFiles, err := projectScaffoldService.Init(cli.Init.BinaryName)
+	logger.Check(err)
+	slog.Info("Project initialized, run bash-compiler to compile it", "filesCount", len(createdFiles))
+}
+
+func runNewBinary(cli *cli) {
+	projectScaffoldService := services.NewProjectScaffoldService(
+		string(cli.RootDirectory), string(cli.BinaryFilesExtension), cli.NewBinary.FrameworkSrcDir,
+	)
+	createdFiles, err := projectScaffoldService.NewBinary(cli.NewBinary.BinaryName)
+	logger.Check(err)
+	slog.Info("Binary model created", "filesCount", len(createdFiles))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fchastanet/bash-compiler/internal/compiler"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+type invalidBinaryLocationError struct {
+	error
+	Location string
+}
+
+func (err *invalidBinaryLocationError) Error() string {
+	return fmt.Sprintf("invalid location '%s', expected <binary>:<line>", err.Location)
+}
+
+type sourceMapNotFoundError struct {
+	error
+	BinaryFile string
+}
+
+func (err *sourceMapNotFoundError) Error() string {
+	return fmt.Sprintf(
+		"no source map found for '%s', enable compilerConfig.sourceMap in its binary model and compile it again",
+		err.BinaryFile,
+	)
+}
+
+func runLocate(cli *cli) {
+	binaryFile, line, err := parseBinaryLocation(cli.Locate.Location)
+	logger.Check(err)
+	sourceMap, err := compiler.LoadSourceMap(binaryFile)
+	if errors.Is(err, os.ErrNotExist) {
+		err = &sourceMapNotFoundError{err, binaryFile}
+	}
+	logger.Check(err)
+	location, err := sourceMap.Locate(line)
+	logger.Check(err)
+	fmt.Println(location.String())
+}
+
+// parseBinaryLocation parses <binary>:<line>
+func parseBinaryLocation(location string) (binaryFile string, line int, err error) {
+	separatorIndex := strings.LastIndex(location, ":")
+	if separatorIndex <= 0 {
+		return "", 0, &invalidBinaryLocationError{nil, location}
+	}
+	line, err = strconv.Atoi(location[separatorIndex+1:])
+	if err != nil || line <= 0 {
+		return "", 0, &invalidBinaryLocationError{err, location}
+	}
+	return location[:separatorIndex], line, nil
+}
@@ -3,15 +3,22 @@ package main
 
 import (
 	"embed"
+	"io/fs"
 	"log/slog"
 	"os"
 
+	"github.com/fchastanet/bash-compiler/internal/render"
 	"github.com/fchastanet/bash-compiler/internal/services"
-	"github.com/fchastanet/bash-compiler/internal/utils/files"
 	"github.com/fchastanet/bash-compiler/internal/utils/logger"
 	"go.uber.org/automaxprocs/maxprocs"
 )
 
+const (
+	defaultTemplatesDir = "defaultTemplates"
+	// defaultTemplateFolder is a virtual directory, it does not exist on disk
+	defaultTemplateFolder = "/bash-compiler-embedded/defaultTemplates"
+)
+
 //go:embed defaultTemplates/*
 var templateFs embed.FS
 
@@ -33,24 +40,51 @@ func main() {
 	logger.Check(err)
 	logger.InitLogger(cli.LogLevel)
 
-	// create temporary directory
-	templateTempDir, err := os.MkdirTemp("", "bash-compiler")
-	logger.Check(err)
-	err = files.CopyEmbeddedFiles(templateFs, "defaultTemplates", templateTempDir)
-	logger.Check(err)
-	err = os.Setenv("DEFAULT_TEMPLATE_FOLDER", templateTempDir)
+	switch cli.Command {
+	case "deps":
+		runDeps(&cli)
+	case "which":
+		runWhich(&cli)
+	case "init":
+		runInit(&cli)
+	case "new-binary":
+		runNewBinary(&cli)
+	case "export-templates":
+		runExportTemplates(&cli)
+	case "locate":
+		runLocate(&cli)
+	case "doc":
+		runDoc(&cli)
+	default:
+		runCompile(&cli)
+	}
+}
+
+// mountDefaultTemplates serves the embedded default templates from the virtual
+// directory DEFAULT_TEMPLATE_FOLDER, nothing is written on disk
+func mountDefaultTemplates() {
+	defaultTemplatesFs, err := fs.Sub(templateFs, defaultTemplatesDir)
 	logger.Check(err)
-	slog.Info("Default template folder", "folder", templateTempDir)
-
-	compilerPipelineService := services.NewCompilerPipelineService(
-		string(cli.RootDirectory),
-		[]string(cli.YamlFiles),
-		string(cli.BinaryFilesExtension),
-		cli.Debug,
-		string(cli.IntermediateFilesDir),
-	)
-	err = compilerPipelineService.Init()
+	render.MountTemplateDir(defaultTemplateFolder, defaultTemplatesFs)
+	err = os.Setenv("DEFAULT_TEMPLATE_FOLDER", defaultTemplateFolder)
 	logger.Check(err)
-	err = compilerPipelineService.ProcessPipeline()
+	slog.Debug("Default template folder", "folder", defaultTemplateFolder)
+}
+
+// newCompilerPipelineService creates and initializes the service
+// with the options common to all the commands, other options are provided by the command
+func newCompilerPipelineService(
+	cli *cli,
+	yamlFiles YamlFiles,
+	options services.CompilerPipelineOptions,
+) *services.CompilerPipelineService {
+	options.RootDirectory = string(cli.RootDirectory)
+	options.YamlFiles = []string(yamlFiles)
+	options.BinaryFilesExtension = string(cli.BinaryFilesExtension)
+	options.Debug = cli.Debug
+	options.IntermediateFilesDir = string(cli.IntermediateFilesDir)
+	compilerPipelineService := services.NewCompilerPipelineService(options)
+	err := compilerPipelineService.Init()
 	logger.Check(err)
+	return compilerPipelineService
 }
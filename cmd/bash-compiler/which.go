@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+
+	"github.com/fchastanet/bash-compiler/internal/services"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+func runWhich(cli *cli) {
+	mountDefaultTemplates()
+	compilerPipelineService := newCompilerPipelineService(
+		cli, cli.Which.YamlFiles, services.CompilerPipelineOptions{}, //nolint:exhaustruct // no compilation
+	)
+	resolutions, err := compilerPipelineService.Which(cli.Which.FunctionName)
+	logger.Check(err)
+	err = services.WriteFunctionResolutions(os.Stdout, resolutions)
+	logger.Check(err)
+}
@@ -0,0 +1,268 @@
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/fchastanet/bash-compiler/internal/model"
+	myTemplateFunctions "github.com/fchastanet/bash-compiler/internal/render"
+	"github.com/fchastanet/bash-compiler/internal/utils/customerrors"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+const (
+	templateFieldAnnotation = "annotation"
+	templateFieldValues     = "values"
+	templateFieldMatches    = "matches"
+	// customAnnotationValueGroup is the regexp group providing the value of the annotation
+	customAnnotationValueGroup = "value"
+)
+
+// reservedAnnotationNames are the annotations processed by the compiler
+var reservedAnnotationNames = []string{
+	annotationRequireKind, annotationDeprecatedKind, annotationRequireCommandKind, annotationRequireEnvKind,
+	annotationPriorityKind, annotationBeforeKind, annotationAfterKind,
+}
+
+// customAnnotationProcessor processes the annotations declared in the configuration,
+// each annotation line matching the regexp of an annotation is removed and
+// the template of the annotation is rendered:
+//   - with the code of the function if the annotation is set on a function
+//   - in place of the annotation line otherwise
+type customAnnotationProcessor struct {
+	annotationProcessor
+	globalAnnotations []model.CustomAnnotationConfig
+	definitions       []*customAnnotationDefinition
+}
+
+type customAnnotationDefinition struct {
+	name         string
+	regexp       *regexp.Regexp
+	templateName string
+}
+
+type customAnnotation struct {
+	annotation
+	values  []string
+	matches []map[string]string
+}
+
+// NewCustomAnnotationProcessor creates the processor of the annotations declared in the
+// binary model, globalAnnotations are the ones declared for every binary model
+func NewCustomAnnotationProcessor(globalAnnotations []model.CustomAnnotationConfig) AnnotationProcessorInterface {
+	return &customAnnotationProcessor{ //nolint:exhaustruct // Check Init method
+		globalAnnotations: globalAnnotations,
+	}
+}
+
+func (*customAnnotationProcessor) GetTitle() string {
+	return "CustomAnnotationProcessor"
+}
+
+func (annotationProcessor *customAnnotationProcessor) Init(
+	compileContextData *CompileContextData,
+) error {
+	if compileContextData == nil {
+		return validationError("compileContextData", nil)
+	}
+	err := compileContextData.Validate()
+	if logger.FancyHandleError(err) {
+		return err
+	}
+	// annotations of the binary model override the global ones
+	annotationConfigs := []model.CustomAnnotationConfig{}
+	annotationIndexes := map[string]int{}
+	for _, annotationConfig := range slices.Concat(
+		annotationProcessor.globalAnnotations, compileContextData.config.CustomAnnotations,
+	) {
+		if index, exists := annotationIndexes[annotationConfig.Name]; exists {
+			annotationConfigs[index] = annotationConfig
+			continue
+		}
+		annotationIndexes[annotationConfig.Name] = len(annotationConfigs)
+		annotationConfigs = append(annotationConfigs, annotationConfig)
+	}
+	annotationProcessor.definitions = make([]*customAnnotationDefinition, 0, len(annotationConfigs))
+	for _, annotationConfig := range annotationConfigs {
+		definition, err := newCustomAnnotationDefinition(compileContextData, annotationConfig)
+		if err != nil {
+			return err
+		}
+		annotationProcessor.definitions = append(annotationProcessor.definitions, definition)
+	}
+	return nil
+}
+
+func newCustomAnnotationDefinition(
+	compileContextData *CompileContextData, annotationConfig model.CustomAnnotationConfig,
+) (*customAnnotationDefinition, error) {
+	if slices.Contains(reservedAnnotationNames, annotationConfig.Name) {
+		return nil, &customerrors.ValidationError{
+			InnerError: nil,
+			Context:    "compileContextData.config.CustomAnnotations",
+			FieldName:  "name",
+			FieldValue: annotationConfig.Name,
+		}
+	}
+	annotationRegexp, err := regexp.Compile(annotationConfig.Regexp)
+	if err != nil {
+		return nil, &customerrors.ValidationError{
+			InnerError: err,
+			Context:    "compileContextData.config.CustomAnnotations." + annotationConfig.Name,
+			FieldName:  "regexp",
+			FieldValue: annotationConfig.Regexp,
+		}
+	}
+	templateName, err := compileContextData.config.AnnotationsConfig.GetStringValue(annotationConfig.TemplateName)
+	if err != nil {
+		return nil, &customerrors.ValidationError{
+			InnerError: err,
+			Context:    "compileContextData.config.AnnotationsConfig",
+			FieldName:  annotationConfig.TemplateName,
+			FieldValue: nil,
+		}
+	}
+	return &customAnnotationDefinition{
+		name:         annotationConfig.Name,
+		regexp:       annotationRegexp,
+		templateName: templateName,
+	}, nil
+}
+
+func (*customAnnotationProcessor) Reset() {
+}
+
+func (annotationProcessor *customAnnotationProcessor) ParseFunction(
+	compileContextData *CompileContextData,
+	functionStruct *functionInfoStruct,
+) error {
+	if len(annotationProcessor.definitions) == 0 {
+		return nil
+	}
+	annotations, code := annotationProcessor.extractAnnotations(functionStruct.SourceCode)
+	if len(annotations) == 0 {
+		return nil
+	}
+	functionStruct.SourceCode = code
+	for _, definition := range annotationProcessor.definitions {
+		foundAnnotation, ok := annotations[definition.name]
+		if !ok {
+			continue
+		}
+		slog.Debug("Custom annotation found",
+			templateFieldAnnotation, definition.name, templateFieldFunctionName, functionStruct.FunctionName,
+		)
+		sourceCode, err := myTemplateFunctions.MustInclude(
+			definition.templateName,
+			map[string]any{
+				templateFieldAnnotation:   definition.name,
+				templateFieldCode:         functionStruct.SourceCode,
+				templateFieldFunctionName: functionStruct.FunctionName,
+				templateFieldValues:       foundAnnotation.values,
+				templateFieldMatches:      foundAnnotation.matches,
+			},
+			*compileContextData.templateContextData,
+		)
+		if err != nil {
+			return err
+		}
+		functionStruct.SourceCode = sourceCode
+		functionStruct.AnnotationMap[definition.name] = *foundAnnotation
+	}
+	return nil
+}
+
+// extractAnnotations returns the annotations found in the code by name
+// and the code without the annotation lines
+func (annotationProcessor *customAnnotationProcessor) extractAnnotations(
+	code string,
+) (annotations map[string]*customAnnotation, newCode string) {
+	var newCodeBuffer bytes.Buffer
+	annotations = map[string]*customAnnotation{}
+	scanner := bufio.NewScanner(strings.NewReader(code))
+	for scanner.Scan() {
+		line := scanner.Text()
+		definition, values := annotationProcessor.matchLine(line)
+		if definition == nil {
+			newCodeBuffer.WriteString(line)
+			newCodeBuffer.WriteByte('\n')
+			continue
+		}
+		foundAnnotation, ok := annotations[definition.name]
+		if !ok {
+			foundAnnotation = &customAnnotation{
+				annotation: annotation{},
+				values:     []string{},
+				matches:    []map[string]string{},
+			}
+			annotations[definition.name] = foundAnnotation
+		}
+		foundAnnotation.values = append(foundAnnotation.values, values[customAnnotationValueGroup])
+		foundAnnotation.matches = append(foundAnnotation.matches, values)
+	}
+	return annotations, newCodeBuffer.String()
+}
+
+// matchLine returns the first annotation matching the line and the values of its named groups
+func (annotationProcessor *customAnnotationProcessor) matchLine(
+	line string,
+) (*customAnnotationDefinition, map[string]string) {
+	for _, definition := range annotationProcessor.definitions {
+		matches := definition.regexp.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		values := map[string]string{}
+		for index, groupName := range definition.regexp.SubexpNames() {
+			if groupName != "" {
+				values[groupName] = strings.TrimSpace(matches[index])
+			}
+		}
+		return definition, values
+	}
+	return nil, nil
+}
+
+func (*customAnnotationProcessor) Process(_ *CompileContextData) error {
+	return nil
+}
+
+// PostProcess replaces the annotation lines that are not part of a function
+// by the rendering of their template
+func (annotationProcessor *customAnnotationProcessor) PostProcess(
+	compileContextData *CompileContextData, code string,
+) (string, error) {
+	if len(annotationProcessor.definitions) == 0 {
+		return code, nil
+	}
+	var bufferOutput bytes.Buffer
+	scanner := bufio.NewScanner(strings.NewReader(code))
+	for scanner.Scan() {
+		line := scanner.Text()
+		definition, values := annotationProcessor.matchLine(line)
+		if definition != nil {
+			output, err := myTemplateFunctions.MustInclude(
+				definition.templateName,
+				map[string]any{
+					templateFieldAnnotation:   definition.name,
+					templateFieldCode:         "",
+					templateFieldFunctionName: "",
+					templateFieldValues:       []string{values[customAnnotationValueGroup]},
+					templateFieldMatches:      []map[string]string{values},
+				},
+				*compileContextData.templateContextData,
+			)
+			if err != nil {
+				return "", err
+			}
+			line = strings.TrimSuffix(output, "\n")
+		}
+		bufferOutput.WriteString(line)
+		bufferOutput.WriteByte('\n')
+	}
+	return bufferOutput.String(), nil
+}
@@ -0,0 +1,127 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/model"
+	"github.com/fchastanet/bash-compiler/internal/render"
+	"github.com/fchastanet/bash-compiler/internal/utils/structures"
+	"gotest.tools/v3/assert"
+)
+
+// getCustomAnnotationCompileContextData renders the templates as
+// "<templateName>(<annotation> <functionName> <values>)[<code>]"
+func getCustomAnnotationCompileContextData(
+	customAnnotations []model.CustomAnnotationConfig,
+) *CompileContextData {
+	return getCompileContextData(
+		&model.CompilerConfig{ //nolint:exhaustruct // test
+			AnnotationsConfig: structures.Dictionary{
+				"experimentalTemplateName": "experimental",
+				"auditTemplateName":        "audit",
+			},
+			CustomAnnotations: customAnnotations,
+		},
+		func(templateContextData *render.TemplateContextData, templateName string) (string, error) {
+			data, ok := templateContextData.Data.(map[string]any)
+			if !ok {
+				return "", validationError("invalid Data", templateContextData.Data)
+			}
+			return fmt.Sprintf("%s(%v %v %v)[%v]",
+				templateName, data["annotation"], data["functionName"], data["values"], data["code"],
+			), nil
+		},
+	)
+}
+
+func TestCustomAnnotationInit(t *testing.T) {
+	t.Run("compileContextData nil", func(t *testing.T) {
+		err := NewCustomAnnotationProcessor(nil).Init(nil)
+		assert.Error(t, err, "validation failed invalid value : "+
+			"context annotationEmbed field compileContextData value <nil>")
+	})
+	t.Run("invalid regexp", func(t *testing.T) {
+		err := NewCustomAnnotationProcessor(nil).Init(getCustomAnnotationCompileContextData(
+			[]model.CustomAnnotationConfig{{Name: "audit", Regexp: "# @audit(", TemplateName: "auditTemplateName"}},
+		))
+		assert.ErrorContains(t, err, "context compileContextData.config.CustomAnnotations.audit field regexp")
+	})
+	t.Run("missing template name", func(t *testing.T) {
+		err := NewCustomAnnotationProcessor(nil).Init(getCustomAnnotationCompileContextData(
+			[]model.CustomAnnotationConfig{{Name: "audit", Regexp: "# @audit", TemplateName: "unknownTemplateName"}},
+		))
+		assert.Error(t, err, "validation failed invalid value : context compileContextData.config.AnnotationsConfig "+
+			"field unknownTemplateName value <nil> inner error missing key: unknownTemplateName")
+	})
+	t.Run("reserved name", func(t *testing.T) {
+		for _, name := range []string{"require", "deprecated", "requireCommand", "requireEnv"} {
+			err := NewCustomAnnotationProcessor(nil).Init(getCustomAnnotationCompileContextData(
+				[]model.CustomAnnotationConfig{{Name: name, Regexp: "# @" + name, TemplateName: "auditTemplateName"}},
+			))
+			assert.Error(t, err, "validation failed invalid value : "+
+				"context compileContextData.config.CustomAnnotations field name value "+name)
+		}
+	})
+}
+
+func TestCustomAnnotationParseFunction(t *testing.T) {
+	globalAnnotations := []model.CustomAnnotationConfig{
+		{Name: "audit", Regexp: `^# @audit$`, TemplateName: "unknownTemplateName"},
+		{Name: "experimental", Regexp: `^# @experimental(?P<value>.*)$`, TemplateName: "experimentalTemplateName"},
+	}
+	compileContextData := getCustomAnnotationCompileContextData([]model.CustomAnnotationConfig{
+		// overrides the global annotation
+		{Name: "audit", Regexp: `^# @audit[[:blank:]]+(?P<value>.*)$`, TemplateName: "auditTemplateName"},
+	})
+	processor := NewCustomAnnotationProcessor(globalAnnotations)
+	assert.NilError(t, processor.Init(compileContextData))
+
+	t.Run("no annotation", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName:  "My::func",
+			SourceCode:    "# @description my func\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.NilError(t, processor.ParseFunction(compileContextData, functionStruct))
+		assert.Equal(t, "# @description my func\nMy::func() { :; }\n", functionStruct.SourceCode)
+		assert.Equal(t, 0, len(functionStruct.AnnotationMap))
+	})
+
+	t.Run("annotations", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName:  "My::func",
+			SourceCode:    "# @audit reviewed by team A\n# @audit  reviewed by team B\n# @experimental\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.NilError(t, processor.ParseFunction(compileContextData, functionStruct))
+		assert.Equal(t,
+			"experimental(experimental My::func [])[audit(audit My::func [reviewed by team A reviewed by team B])"+
+				"[My::func() { :; }\n]\n]\n",
+			functionStruct.SourceCode,
+		)
+		annotationNames := structures.MapKeys(functionStruct.AnnotationMap)
+		sort.Strings(annotationNames)
+		assert.DeepEqual(t, []string{"audit", "experimental"}, annotationNames)
+	})
+}
+
+func TestCustomAnnotationPostProcess(t *testing.T) {
+	compileContextData := getCustomAnnotationCompileContextData([]model.CustomAnnotationConfig{
+		{Name: "audit", Regexp: `^# @audit[[:blank:]]+(?P<value>.*)$`, TemplateName: "auditTemplateName"},
+	})
+	processor := NewCustomAnnotationProcessor(nil)
+	assert.NilError(t, processor.Init(compileContextData))
+
+	code, err := processor.PostProcess(compileContextData, "#!/bin/bash\n# @audit main code\necho main\n")
+	assert.NilError(t, err)
+	assert.Equal(t, "#!/bin/bash\naudit(audit  [main code])[]\necho main\n", code)
+
+	// processor without annotation does not change the code
+	processor = NewCustomAnnotationProcessor(nil)
+	assert.NilError(t, processor.Init(getCustomAnnotationCompileContextData(nil)))
+	code, err = processor.PostProcess(compileContextData, "# @audit main code")
+	assert.NilError(t, err)
+	assert.Equal(t, "# @audit main code", code)
+}
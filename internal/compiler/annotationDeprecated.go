@@ -0,0 +1,141 @@
+package compiler
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+var deprecatedRegexp = regexp.MustCompile(
+	`^[[:blank:]]*# @deprecated([[:blank:]]+(?P<replacement>[^[:blank:]]+))?([[:blank:]]+(?P<message>.*))?$`,
+)
+
+const annotationDeprecatedKind string = "deprecated"
+
+// DeprecatedFunctionUsage describes a deprecated function included in the compiled code
+type DeprecatedFunctionUsage struct {
+	FunctionName string `json:"functionName"`
+	// Replacement is the function to use instead, empty if not provided
+	Replacement string `json:"replacement,omitempty"`
+	Message     string `json:"message,omitempty"`
+	// ReferenceChain is the shortest chain of references explaining why the function is included
+	ReferenceChain string `json:"referenceChain"`
+}
+
+type deprecatedFunctionsProviderInterface interface {
+	getDeprecatedFunctionUsages() []DeprecatedFunctionUsage
+}
+
+type deprecatedAnnotationProcessor struct {
+	annotationProcessor
+	usages []DeprecatedFunctionUsage
+}
+
+type deprecatedAnnotation struct {
+	annotation
+	replacement string
+	message     string
+}
+
+func NewDeprecatedAnnotationProcessor() AnnotationProcessorInterface {
+	return &deprecatedAnnotationProcessor{} //nolint:exhaustruct // Check Init method
+}
+
+func (*deprecatedAnnotationProcessor) GetTitle() string {
+	return "DeprecatedAnnotationProcessor"
+}
+
+func (annotationProcessor *deprecatedAnnotationProcessor) Init(
+	compileContextData *CompileContextData,
+) error {
+	if compileContextData == nil {
+		return validationError("compileContextData", nil)
+	}
+	err := compileContextData.Validate()
+	if logger.FancyHandleError(err) {
+		return err
+	}
+	annotationProcessor.usages = []DeprecatedFunctionUsage{}
+	return nil
+}
+
+func (*deprecatedAnnotationProcessor) Reset() {
+}
+
+// ParseFunction records the @deprecated annotation of the function,
+// the annotation line is kept as it is a comment
+func (*deprecatedAnnotationProcessor) ParseFunction(
+	_ *CompileContextData,
+	functionStruct *functionInfoStruct,
+) error {
+	scanner := bufio.NewScanner(strings.NewReader(functionStruct.SourceCode))
+	for scanner.Scan() {
+		matches := deprecatedRegexp.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+		functionStruct.AnnotationMap[annotationDeprecatedKind] = deprecatedAnnotation{
+			annotation:  annotation{},
+			replacement: matches[deprecatedRegexp.SubexpIndex("replacement")],
+			message:     strings.TrimSpace(matches[deprecatedRegexp.SubexpIndex("message")]),
+		}
+		return nil
+	}
+	return nil
+}
+
+func (*deprecatedAnnotationProcessor) Process(_ *CompileContextData) error {
+	return nil
+}
+
+func (*deprecatedAnnotationProcessor) PostProcess(
+	_ *CompileContextData, code string,
+) (string, error) {
+	return code, nil
+}
+
+func (functionStruct *functionInfoStruct) getDeprecatedAnnotation() (*deprecatedAnnotation, bool) {
+	castedAnnotation, ok := functionStruct.AnnotationMap[annotationDeprecatedKind].(deprecatedAnnotation)
+	return &castedAnnotation, ok
+}
+
+func (*deprecatedAnnotationProcessor) isDependencyGraphNeeded(compileContextData *CompileContextData) bool {
+	for _, functionInfo := range compileContextData.functionsMap {
+		if _, deprecated := functionInfo.getDeprecatedAnnotation(); deprecated {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDependencyGraph records the deprecated functions included in the binary
+// with the chain of references leading to them
+func (annotationProcessor *deprecatedAnnotationProcessor) checkDependencyGraph(
+	compileContextData *CompileContextData, graph *DependencyGraph,
+) {
+	annotationProcessor.usages = []DeprecatedFunctionUsage{}
+	for _, functionName := range getSortedFunctionNamesFromMap(compileContextData.functionsMap) {
+		functionInfo := compileContextData.functionsMap[functionName]
+		deprecatedAnnotation, deprecated := functionInfo.getDeprecatedAnnotation()
+		if !deprecated {
+			continue
+		}
+		referenceChain := graph.Binary + " -> " + functionName
+		chain, err := graph.ReferenceChain(functionName)
+		if err == nil {
+			referenceChain = graph.FormatReferenceChain(chain)
+		}
+		annotationProcessor.usages = append(annotationProcessor.usages, DeprecatedFunctionUsage{
+			FunctionName:   functionName,
+			Replacement:    deprecatedAnnotation.replacement,
+			Message:        deprecatedAnnotation.message,
+			ReferenceChain: referenceChain,
+		})
+	}
+}
+
+func (annotationProcessor *deprecatedAnnotationProcessor) getDeprecatedFunctionUsages() []DeprecatedFunctionUsage {
+	return annotationProcessor.usages
+}
@@ -0,0 +1,96 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/model"
+	"gotest.tools/v3/assert"
+)
+
+func TestDeprecatedAnnotationInit(t *testing.T) {
+	err := NewDeprecatedAnnotationProcessor().Init(nil)
+	assert.Error(t, err, "validation failed invalid value : "+
+		"context annotationEmbed field compileContextData value <nil>")
+}
+
+func TestDeprecatedAnnotationParseFunction(t *testing.T) {
+	processor := NewDeprecatedAnnotationProcessor()
+	t.Run("no annotation", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			SourceCode:    "# @description my func\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.NilError(t, processor.ParseFunction(nil, functionStruct))
+		_, deprecated := functionStruct.getDeprecatedAnnotation()
+		assert.Assert(t, !deprecated)
+	})
+	t.Run("annotation without replacement", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			SourceCode:    "# @deprecated\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.NilError(t, processor.ParseFunction(nil, functionStruct))
+		deprecatedAnnotation, deprecated := functionStruct.getDeprecatedAnnotation()
+		assert.Assert(t, deprecated)
+		assert.Equal(t, "", deprecatedAnnotation.replacement)
+		assert.Equal(t, "", deprecatedAnnotation.message)
+	})
+	t.Run("annotation with replacement and message", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			SourceCode:    "# @deprecated New::func  will be removed in next version \nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.NilError(t, processor.ParseFunction(nil, functionStruct))
+		deprecatedAnnotation, deprecated := functionStruct.getDeprecatedAnnotation()
+		assert.Assert(t, deprecated)
+		assert.Equal(t, "New::func", deprecatedAnnotation.replacement)
+		assert.Equal(t, "will be removed in next version", deprecatedAnnotation.message)
+		// annotation is kept in the code
+		assert.Equal(t, "# @deprecated New::func  will be removed in next version \nMy::func() { :; }\n",
+			functionStruct.SourceCode)
+	})
+}
+
+func newDeprecatedCompileContextData(t *testing.T, srcDir string) *CompileContextData {
+	t.Helper()
+	return initCompileContextData(t, getCompileContextData(
+		&model.CompilerConfig{ //nolint:exhaustruct // test
+			TargetFile: "/tmp/bin/myBinary",
+			SrcDirs:    []string{srcDir},
+		},
+		nil,
+	), NewDeprecatedAnnotationProcessor())
+}
+
+func TestDeprecatedFunctionUsages(t *testing.T) {
+	srcDir := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(srcDir, "Old"), 0o755))
+	assert.NilError(t, os.WriteFile(filepath.Join(srcDir, "Old", "function.sh"), []byte(
+		"#!/bin/bash\n# @deprecated New::function use the new one\nOld::function() { :; }\n",
+	), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(srcDir, "Old", "caller.sh"), []byte(
+		"#!/bin/bash\nOld::caller() {\n  Old::function\n}\n",
+	), 0o600))
+
+	t.Run("deprecated function included", func(t *testing.T) {
+		compileContextData := newDeprecatedCompileContextData(t, srcDir)
+		_, err := compileContextData.compileContext.Compile(compileContextData, "# FUNCTIONS\nOld::caller\n")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, []DeprecatedFunctionUsage{
+			{
+				FunctionName:   "Old::function",
+				Replacement:    "New::function",
+				Message:        "use the new one",
+				ReferenceChain: "myBinary -[call]-> Old::caller -[call]-> Old::function",
+			},
+		}, compileContextData.GetDeprecatedFunctionUsages())
+	})
+	t.Run("no deprecated function", func(t *testing.T) {
+		compileContextData := newDeprecatedCompileContextData(t, srcDir)
+		_, err := compileContextData.compileContext.Compile(compileContextData, "# FUNCTIONS\necho hello\n")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, []DeprecatedFunctionUsage{}, compileContextData.GetDeprecatedFunctionUsages())
+	})
+}
@@ -110,6 +110,10 @@ func (annotationProcessor *embedAnnotationProcessor) Reset() {
 	annotationProcessor.embedMap = make(map[string]string)
 }
 
+func (annotationProcessor *embedAnnotationProcessor) getEmbeddedResources() map[string]string {
+	return annotationProcessor.embedMap
+}
+
 func (*embedAnnotationProcessor) ParseFunction(
 	_ *CompileContextData,
 	_ *functionInfoStruct,
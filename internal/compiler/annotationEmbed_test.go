@@ -91,7 +91,7 @@ func TestEmbedInitInvalidCompileContextDataMissingEmbedFileTemplateDir(t *testin
 
 func getValidEmbedProcessor(t *testing.T) AnnotationProcessorInterface {
 	embedProcessor := NewEmbedAnnotationProcessor()
-	err := embedProcessor.Init(getCompileContextData())
+	err := embedProcessor.Init(getCompileContextData(nil, nil))
 	assert.Equal(t, nil, err)
 	return embedProcessor
 }
@@ -151,21 +151,45 @@ func getEmbedProcessorMocked(generateCodeFunc GenerateCodeFunc) *embedAnnotation
 	return embedProcessor
 }
 
-func getCompileContextData() *CompileContextData {
-	return &CompileContextData{
-		&CompileContext{},             //nolint:exhaustruct // test
-		&render.TemplateContextData{}, //nolint:exhaustruct // test
-		&model.CompilerConfig{ //nolint:exhaustruct // test
+// getCompileContextData returns a compile context data using the embed annotations config
+// if config is nil, the templates are rendered using templateContextRenderFunc
+func getCompileContextData(
+	config *model.CompilerConfig, templateContextRenderFunc TemplateContextRenderFunc,
+) *CompileContextData {
+	if config == nil {
+		config = &model.CompilerConfig{ //nolint:exhaustruct // test
 			AnnotationsConfig: structures.Dictionary{
 				"embedFileTemplateName": "templateName",
 				"embedDirTemplateName":  "templateDir",
 			},
+		}
+	}
+	templateName := "fakeTemplate"
+	return &CompileContextData{
+		&CompileContext{}, //nolint:exhaustruct // test
+		&render.TemplateContextData{ //nolint:exhaustruct // test
+			TemplateContext: &templateContextMock{templateContextRenderFunc, simulateGoodRenderingCallback},
+			TemplateName:    &templateName,
 		},
+		config,
 		make(map[string]functionInfoStruct),
 		[]*regexp.Regexp{},
 	}
 }
 
+// initCompileContextData initializes a compiler using the annotation processors provided
+// and the templates and config of compileContextData
+func initCompileContextData(
+	t *testing.T, compileContextData *CompileContextData, annotationProcessors ...AnnotationProcessorInterface,
+) *CompileContextData {
+	t.Helper()
+	templateContextData := compileContextData.templateContextData
+	compileContext := NewCompiler(templateContextData.TemplateContext, annotationProcessors)
+	compileContextData, err := compileContext.Init(templateContextData, compileContextData.config)
+	assert.NilError(t, err)
+	return compileContextData
+}
+
 func TestEmbedPostProcessOneMatch(t *testing.T) {
 	mock := getEmbedProcessorMocked(
 		func() (string, error) {
@@ -173,7 +197,7 @@ func TestEmbedPostProcessOneMatch(t *testing.T) {
 		},
 	)
 	var embedProcessor AnnotationProcessorInterface = mock
-	compileContextData := getCompileContextData()
+	compileContextData := getCompileContextData(nil, nil)
 	code, err := embedProcessor.PostProcess(compileContextData, "# @embed srcFile AS targetFile")
 	assert.Equal(t, nil, err)
 	assert.Equal(t, "mock\n", code)
@@ -186,7 +210,7 @@ func TestEmbedPostProcessTwoMatches(t *testing.T) {
 		},
 	)
 	var embedProcessor AnnotationProcessorInterface = mock
-	compileContextData := getCompileContextData()
+	compileContextData := getCompileContextData(nil, nil)
 	code, err := embedProcessor.PostProcess(
 		compileContextData,
 		"# @embed srcFile AS targetFile\n# @embed srcFile AS targetFile",
@@ -200,7 +224,7 @@ func TestEmbedPostProcessGenerateError(t *testing.T) {
 		return "", &unsupportedEmbeddedResourceError{nil, "asName", "resource", 12}
 	})
 	var embedProcessor AnnotationProcessorInterface = mock
-	compileContextData := getCompileContextData()
+	compileContextData := getCompileContextData(nil, nil)
 	code, err := embedProcessor.PostProcess(
 		compileContextData,
 		"# @embed srcFile AS targetFile\n# @embed srcFile AS targetFile",
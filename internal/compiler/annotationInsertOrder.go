@@ -0,0 +1,397 @@
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+var (
+	priorityRegexp     = regexp.MustCompile(`^[[:blank:]]*# @priority([[:blank:]].*)?$`)
+	priorityArgsRegexp = regexp.MustCompile(`^[[:blank:]]+(?P<priority>-?[0-9]+)[[:blank:]]*$`)
+	beforeAfterRegexp  = regexp.MustCompile(`^[[:blank:]]*# @(?P<kind>before|after)([[:blank:]].*)?$`)
+)
+
+const (
+	annotationPriorityKind string = "priority"
+	annotationBeforeKind   string = "before"
+	annotationAfterKind    string = "after"
+	// annotationInsertOrderKind is the key of the insertOrderAnnotation in the AnnotationMap
+	annotationInsertOrderKind string = "insertOrder"
+)
+
+type invalidInsertOrderError struct {
+	error
+	FunctionName string
+	Line         string
+	Expected     string
+}
+
+func (e *invalidInsertOrderError) Error() string {
+	return fmt.Sprintf(
+		"invalid annotation '%s' in %s, expected: %s",
+		strings.TrimSpace(e.Line), e.FunctionName, e.Expected,
+	)
+}
+
+type insertOrderCycleError struct {
+	error
+	Cycle []string
+}
+
+func (e *insertOrderCycleError) Error() string {
+	return "@before/@after cycle detected: " + strings.Join(e.Cycle, " -> ")
+}
+
+// insertOrderAnnotationProcessor removes the @priority, @before and @after annotations
+// from the functions code, they are used to sort the functions when generating the code
+type insertOrderAnnotationProcessor struct {
+	annotationProcessor
+}
+
+type insertOrderAnnotation struct {
+	annotation
+	// priority functions with the lowest priority are inserted first, default 0
+	priority int
+	// before functions inserted after this function
+	before []string
+	// after functions inserted before this function
+	after []string
+	// calledFunctions functions called by this function according to the dependency graph
+	calledFunctions []string
+}
+
+func NewInsertOrderAnnotationProcessor() AnnotationProcessorInterface {
+	return &insertOrderAnnotationProcessor{} //nolint:exhaustruct // Check Init method
+}
+
+func (*insertOrderAnnotationProcessor) GetTitle() string {
+	return "InsertOrderAnnotationProcessor"
+}
+
+func (*insertOrderAnnotationProcessor) Init(
+	compileContextData *CompileContextData,
+) error {
+	if compileContextData == nil {
+		return validationError("compileContextData", nil)
+	}
+	err := compileContextData.Validate()
+	if logger.FancyHandleError(err) {
+		return err
+	}
+	return nil
+}
+
+func (*insertOrderAnnotationProcessor) Reset() {
+}
+
+// ParseFunction removes the @priority, @before and @after annotations from the function code
+// and records them
+func (*insertOrderAnnotationProcessor) ParseFunction(
+	_ *CompileContextData,
+	functionStruct *functionInfoStruct,
+) error {
+	foundAnnotation, found, code, err := extractInsertOrderAnnotation(
+		functionStruct.SourceCode, functionStruct.FunctionName,
+	)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	functionStruct.SourceCode = code
+	functionStruct.AnnotationMap[annotationInsertOrderKind] = foundAnnotation
+	return nil
+}
+
+func extractInsertOrderAnnotation(code string, functionName string) (
+	foundAnnotation insertOrderAnnotation, found bool, newCode string, err error,
+) {
+	var newCodeBuffer bytes.Buffer
+	foundAnnotation = insertOrderAnnotation{
+		annotation:      annotation{},
+		priority:        0,
+		before:          []string{},
+		after:           []string{},
+		calledFunctions: []string{},
+	}
+	scanner := bufio.NewScanner(strings.NewReader(code))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := priorityRegexp.FindStringSubmatch(line); matches != nil {
+			args := priorityArgsRegexp.FindStringSubmatch(matches[1])
+			if args == nil {
+				return foundAnnotation, false, "", &invalidInsertOrderError{
+					nil, functionName, line, "# @priority <integer>",
+				}
+			}
+			foundAnnotation.priority, err = strconv.Atoi(args[priorityArgsRegexp.SubexpIndex(annotationPriorityKind)])
+			if err != nil {
+				return foundAnnotation, false, "", &invalidInsertOrderError{
+					err, functionName, line, "# @priority <integer>",
+				}
+			}
+			found = true
+			continue
+		}
+		if matches := beforeAfterRegexp.FindStringSubmatch(line); matches != nil {
+			kind := matches[beforeAfterRegexp.SubexpIndex("kind")]
+			functionNames := strings.Fields(matches[2])
+			if len(functionNames) == 0 {
+				return foundAnnotation, false, "", &invalidInsertOrderError{
+					nil, functionName, line, "# @" + kind + " <function> [<function>...]",
+				}
+			}
+			if kind == annotationBeforeKind {
+				foundAnnotation.before = append(foundAnnotation.before, functionNames...)
+			} else {
+				foundAnnotation.after = append(foundAnnotation.after, functionNames...)
+			}
+			found = true
+			continue
+		}
+		newCodeBuffer.WriteString(line)
+		newCodeBuffer.WriteByte('\n')
+	}
+	return foundAnnotation, found, newCodeBuffer.String(), nil
+}
+
+func (*insertOrderAnnotationProcessor) Process(_ *CompileContextData) error {
+	return nil
+}
+
+func (*insertOrderAnnotationProcessor) PostProcess(
+	_ *CompileContextData, code string,
+) (string, error) {
+	return code, nil
+}
+
+// isDependencyGraphNeeded the functions called by a function are inserted before it
+func (*insertOrderAnnotationProcessor) isDependencyGraphNeeded(compileContextData *CompileContextData) bool {
+	return len(compileContextData.functionsMap) > 1
+}
+
+// checkDependencyGraph records the functions called by each function
+func (*insertOrderAnnotationProcessor) checkDependencyGraph(
+	compileContextData *CompileContextData, graph *DependencyGraph,
+) {
+	for _, edge := range graph.Edges {
+		functionInfo, ok := compileContextData.functionsMap[edge.From]
+		if !ok || edge.Kind != DependencyEdgeKindCall {
+			continue
+		}
+		foundAnnotation := functionInfo.getInsertOrderAnnotation()
+		foundAnnotation.calledFunctions = append(foundAnnotation.calledFunctions, edge.To)
+		functionInfo.AnnotationMap[annotationInsertOrderKind] = foundAnnotation
+	}
+}
+
+func (functionStruct *functionInfoStruct) getInsertOrderAnnotation() insertOrderAnnotation {
+	foundAnnotation, ok := functionStruct.AnnotationMap[annotationInsertOrderKind].(insertOrderAnnotation)
+	if !ok {
+		return insertOrderAnnotation{
+			annotation:      annotation{},
+			priority:        0,
+			before:          []string{},
+			after:           []string{},
+			calledFunctions: []string{},
+		}
+	}
+	return foundAnnotation
+}
+
+// sortFunctionsToInsert returns the functions inserted at insertPosition in the order they
+// have to be inserted (topological sort of the @before and @after constraints):
+//   - @before and @after annotations are always respected,
+//   - then the functions with the lowest @priority are inserted first,
+//   - then the functions called by a function are inserted before it, so that top level
+//     code of a function file can call them (ignored for recursive calls),
+//   - then the functions are sorted by name.
+//
+// An error is returned if the @before and @after annotations form a cycle
+func sortFunctionsToInsert(
+	functionsMap map[string]functionInfoStruct, insertPosition InsertPosition,
+) ([]string, error) {
+	functionNames := []string{}
+	isAtPosition := map[string]bool{}
+	for _, functionName := range getSortedFunctionNamesFromMap(functionsMap) {
+		if functionsMap[functionName].InsertPosition == insertPosition {
+			functionNames = append(functionNames, functionName)
+			isAtPosition[functionName] = true
+		}
+	}
+	mustBeInsertedAfter := getInsertOrderConstraints(functionsMap, functionNames)
+	if cycle := findInsertOrderCycle(functionNames, mustBeInsertedAfter); cycle != nil {
+		return nil, &insertOrderCycleError{nil, cycle}
+	}
+
+	queue := &insertOrderQueue{
+		items:        []string{},
+		indexes:      make(map[string]int, len(functionNames)),
+		priorities:   make(map[string]int, len(functionNames)),
+		pendingCalls: make(map[string]int, len(functionNames)),
+	}
+	pendingConstraints := make(map[string]int, len(functionNames))
+	nextFunctions := make(map[string][]string, len(functionNames))
+	callers := make(map[string][]string, len(functionNames))
+	for _, functionName := range functionNames {
+		functionInfo := functionsMap[functionName]
+		foundAnnotation := functionInfo.getInsertOrderAnnotation()
+		queue.priorities[functionName] = foundAnnotation.priority
+		for _, calledFunction := range foundAnnotation.calledFunctions {
+			if calledFunction != functionName && isAtPosition[calledFunction] &&
+				!slices.Contains(callers[calledFunction], functionName) {
+				callers[calledFunction] = append(callers[calledFunction], functionName)
+				queue.pendingCalls[functionName]++
+			}
+		}
+		pendingConstraints[functionName] = len(mustBeInsertedAfter[functionName])
+		for _, previousFunctionName := range mustBeInsertedAfter[functionName] {
+			nextFunctions[previousFunctionName] = append(nextFunctions[previousFunctionName], functionName)
+		}
+	}
+	for _, functionName := range functionNames {
+		if pendingConstraints[functionName] == 0 {
+			heap.Push(queue, functionName)
+		}
+	}
+
+	sortedFunctionNames := make([]string, 0, len(functionNames))
+	for queue.Len() > 0 {
+		functionName, _ := heap.Pop(queue).(string)
+		sortedFunctionNames = append(sortedFunctionNames, functionName)
+		for _, caller := range callers[functionName] {
+			queue.pendingCalls[caller]--
+			if index, queued := queue.indexes[caller]; queued {
+				heap.Fix(queue, index)
+			}
+		}
+		for _, nextFunctionName := range nextFunctions[functionName] {
+			pendingConstraints[nextFunctionName]--
+			if pendingConstraints[nextFunctionName] == 0 {
+				heap.Push(queue, nextFunctionName)
+			}
+		}
+	}
+	return sortedFunctionNames, nil
+}
+
+// insertOrderQueue is the heap of the functions whose @before/@after constraints are satisfied,
+// the lowest priority first, then the functions not calling a function not inserted yet, then by name
+type insertOrderQueue struct {
+	items        []string
+	indexes      map[string]int
+	priorities   map[string]int
+	pendingCalls map[string]int
+}
+
+func (queue *insertOrderQueue) Len() int {
+	return len(queue.items)
+}
+
+func (queue *insertOrderQueue) Less(i, j int) bool {
+	first, second := queue.items[i], queue.items[j]
+	if queue.priorities[first] != queue.priorities[second] {
+		return queue.priorities[first] < queue.priorities[second]
+	}
+	if (queue.pendingCalls[first] == 0) != (queue.pendingCalls[second] == 0) {
+		return queue.pendingCalls[first] == 0
+	}
+	return first < second
+}
+
+func (queue *insertOrderQueue) Swap(i, j int) {
+	queue.items[i], queue.items[j] = queue.items[j], queue.items[i]
+	queue.indexes[queue.items[i]] = i
+	queue.indexes[queue.items[j]] = j
+}
+
+func (queue *insertOrderQueue) Push(item any) {
+	functionName, _ := item.(string)
+	queue.indexes[functionName] = len(queue.items)
+	queue.items = append(queue.items, functionName)
+}
+
+func (queue *insertOrderQueue) Pop() any {
+	functionName := queue.items[len(queue.items)-1]
+	queue.items = queue.items[:len(queue.items)-1]
+	delete(queue.indexes, functionName)
+	return functionName
+}
+
+// getInsertOrderConstraints returns for each function the functions that have to be inserted before it
+// according to @before and @after annotations, functions not included or inserted at another
+// position are ignored
+func getInsertOrderConstraints(
+	functionsMap map[string]functionInfoStruct, functionNames []string,
+) map[string][]string {
+	mustBeInsertedAfter := make(map[string][]string, len(functionNames))
+	addConstraint := func(functionName string, previousFunctionName string) {
+		if !slices.Contains(functionNames, previousFunctionName) || !slices.Contains(functionNames, functionName) {
+			slog.Debug("Insert order constraint ignored",
+				logger.LogFieldFunc, functionName, "previousFunc", previousFunctionName,
+			)
+			return
+		}
+		if !slices.Contains(mustBeInsertedAfter[functionName], previousFunctionName) {
+			mustBeInsertedAfter[functionName] = append(mustBeInsertedAfter[functionName], previousFunctionName)
+		}
+	}
+	for _, functionName := range functionNames {
+		functionInfo := functionsMap[functionName]
+		foundAnnotation := functionInfo.getInsertOrderAnnotation()
+		for _, nextFunctionName := range foundAnnotation.before {
+			addConstraint(nextFunctionName, functionName)
+		}
+		for _, previousFunctionName := range foundAnnotation.after {
+			addConstraint(functionName, previousFunctionName)
+		}
+	}
+	return mustBeInsertedAfter
+}
+
+// findInsertOrderCycle returns the first cycle of the @before/@after constraints,
+// the first function of the cycle being repeated at the end, nil if none
+func findInsertOrderCycle(functionNames []string, mustBeInsertedAfter map[string][]string) []string {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	states := make(map[string]int)
+	path := []string{}
+	var visit func(functionName string) []string
+	visit = func(functionName string) []string {
+		switch states[functionName] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := slices.Clone(path[slices.Index(path, functionName):])
+			return append(cycle, functionName)
+		}
+		states[functionName] = visiting
+		path = append(path, functionName)
+		for _, previousFunctionName := range mustBeInsertedAfter[functionName] {
+			if cycle := visit(previousFunctionName); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		states[functionName] = visited
+		return nil
+	}
+	for _, functionName := range functionNames {
+		if cycle := visit(functionName); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
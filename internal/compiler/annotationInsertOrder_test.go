@@ -0,0 +1,153 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/model"
+	"gotest.tools/v3/assert"
+)
+
+func TestInsertOrderInit(t *testing.T) {
+	err := NewInsertOrderAnnotationProcessor().Init(nil)
+	assert.Error(t, err, "validation failed invalid value : "+
+		"context annotationEmbed field compileContextData value <nil>")
+}
+
+func TestInsertOrderParseFunction(t *testing.T) {
+	processor := NewInsertOrderAnnotationProcessor()
+	t.Run("no annotation", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			SourceCode:    "# @prior 1\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.NilError(t, processor.ParseFunction(nil, functionStruct))
+		assert.Equal(t, "# @prior 1\nMy::func() { :; }\n", functionStruct.SourceCode)
+		assert.Equal(t, 0, len(functionStruct.AnnotationMap))
+	})
+	t.Run("annotations", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName: "My::func",
+			SourceCode: "# @priority -10\n# @before A::func B::func\n  # @after C::func \n" +
+				"# @before D::func\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.NilError(t, processor.ParseFunction(nil, functionStruct))
+		assert.Equal(t, "My::func() { :; }\n", functionStruct.SourceCode)
+		foundAnnotation := functionStruct.getInsertOrderAnnotation()
+		assert.Equal(t, -10, foundAnnotation.priority)
+		assert.DeepEqual(t, []string{"A::func", "B::func", "D::func"}, foundAnnotation.before)
+		assert.DeepEqual(t, []string{"C::func"}, foundAnnotation.after)
+	})
+	t.Run("invalid priority", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName:  "My::func",
+			SourceCode:    "# @priority high\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.Error(t, processor.ParseFunction(nil, functionStruct),
+			"invalid annotation '# @priority high' in My::func, expected: # @priority <integer>")
+	})
+	t.Run("missing function", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName:  "My::func",
+			SourceCode:    "# @after\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.Error(t, processor.ParseFunction(nil, functionStruct),
+			"invalid annotation '# @after' in My::func, expected: # @after <function> [<function>...]")
+	})
+}
+
+func TestSortFunctionsToInsert(t *testing.T) {
+	newFunction := func(
+		insertPosition InsertPosition, calledFunctions []string, priority int, before []string, after []string,
+	) functionInfoStruct {
+		functionInfo := createFunctionInfoStruct("", "", insertPosition)
+		functionInfo.AnnotationMap[annotationInsertOrderKind] = insertOrderAnnotation{
+			annotation:      annotation{},
+			priority:        priority,
+			before:          before,
+			after:           after,
+			calledFunctions: calledFunctions,
+		}
+		return functionInfo
+	}
+	t.Run("called functions first", func(t *testing.T) {
+		functionNames, err := sortFunctionsToInsert(map[string]functionInfoStruct{
+			"A::caller":    newFunction(InsertPositionMiddle, []string{"B::callee"}, 0, nil, nil),
+			"B::callee":    newFunction(InsertPositionMiddle, []string{"C::leaf"}, 0, nil, nil),
+			"C::leaf":      newFunction(InsertPositionMiddle, nil, 0, nil, nil),
+			"D::recursive": newFunction(InsertPositionMiddle, []string{"E::recursive"}, 0, nil, nil),
+			"E::recursive": newFunction(InsertPositionMiddle, []string{"D::recursive"}, 0, nil, nil),
+			"/src/A/_.sh":  newFunction(InsertPositionFirst, []string{"A::caller"}, 0, nil, nil),
+		}, InsertPositionMiddle)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, []string{"C::leaf", "B::callee", "A::caller", "D::recursive", "E::recursive"}, functionNames)
+	})
+	t.Run("priority", func(t *testing.T) {
+		functionNames, err := sortFunctionsToInsert(map[string]functionInfoStruct{
+			"A::caller": newFunction(InsertPositionMiddle, []string{"B::callee"}, -1, nil, nil),
+			"B::callee": newFunction(InsertPositionMiddle, nil, 0, nil, nil),
+			"C::last":   newFunction(InsertPositionMiddle, nil, 10, nil, nil),
+		}, InsertPositionMiddle)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, []string{"A::caller", "B::callee", "C::last"}, functionNames)
+	})
+	t.Run("before and after", func(t *testing.T) {
+		functionNames, err := sortFunctionsToInsert(map[string]functionInfoStruct{
+			"A::func": newFunction(InsertPositionMiddle, nil, -1, nil, []string{"C::func"}),
+			"B::func": newFunction(InsertPositionMiddle, nil, 0, []string{"C::func", "Unknown::func"}, nil),
+			"C::func": newFunction(InsertPositionMiddle, nil, 10, nil, nil),
+			"D::func": newFunction(InsertPositionLast, nil, 0, []string{"A::func"}, nil),
+		}, InsertPositionMiddle)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, []string{"B::func", "C::func", "A::func"}, functionNames)
+	})
+	t.Run("cycle", func(t *testing.T) {
+		_, err := sortFunctionsToInsert(map[string]functionInfoStruct{
+			"A::func": newFunction(InsertPositionMiddle, nil, 0, []string{"B::func"}, nil),
+			"B::func": newFunction(InsertPositionMiddle, nil, 0, []string{"C::func"}, nil),
+			"C::func": newFunction(InsertPositionMiddle, nil, 0, []string{"A::func"}, nil),
+			"D::func": newFunction(InsertPositionMiddle, nil, 0, nil, []string{"C::func"}),
+		}, InsertPositionMiddle)
+		assert.Error(t, err, "@before/@after cycle detected: A::func -> C::func -> B::func -> A::func")
+	})
+}
+
+func TestInsertOrderCompile(t *testing.T) {
+	srcDir := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(srcDir, "My"), 0o755))
+	assert.NilError(t, os.WriteFile(filepath.Join(srcDir, "My", "caller.sh"), []byte(
+		"#!/bin/bash\n# @after My::other\nMy::caller() { My::callee; }\n",
+	), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(srcDir, "My", "callee.sh"), []byte(
+		"#!/bin/bash\nMy::callee() { :; }\n",
+	), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(srcDir, "My", "other.sh"), []byte(
+		"#!/bin/bash\n# @priority 10\nMy::other() { :; }\n",
+	), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(srcDir, "My", "alpha.sh"), []byte(
+		"#!/bin/bash\nMy::alpha() { My::zeta; }\n",
+	), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(srcDir, "My", "zeta.sh"), []byte(
+		"#!/bin/bash\nMy::zeta() { :; }\n",
+	), 0o600))
+	compileContextData := initCompileContextData(t, getCompileContextData(
+		&model.CompilerConfig{SrcDirs: []string{srcDir}}, //nolint:exhaustruct // test
+		nil,
+	), NewInsertOrderAnnotationProcessor())
+
+	code, err := compileContextData.compileContext.Compile(compileContextData, "# FUNCTIONS\nMy::caller\nMy::other\nMy::alpha\n")
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(code, "@priority"))
+	assert.Assert(t, !strings.Contains(code, "@after"))
+	calleeIndex := strings.Index(code, "My::callee() {")
+	otherIndex := strings.Index(code, "My::other() {")
+	callerIndex := strings.Index(code, "My::caller() {")
+	assert.Assert(t, calleeIndex >= 0 && calleeIndex < otherIndex && otherIndex < callerIndex)
+	// functions called according to the dependency graph inserted first
+	assert.Assert(t, strings.Index(code, "My::zeta() {") < strings.Index(code, "My::alpha() {"))
+}
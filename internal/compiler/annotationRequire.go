@@ -3,9 +3,12 @@ package compiler
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
@@ -20,12 +23,15 @@ var (
 		`(?m)[ \t]*(function[ \t]+|)` +
 			`(?P<bashFrameworkFunction>([A-Za-z0-9_]+[A-Za-z0-9_-]*::)+([a-zA-Z0-9_-]+))\(\)[ \t]*\{[ \t]*$`,
 	)
+	// requireBootstrapRegexp is the line of the template replaced by the calls of the requirements
+	requireBootstrapRegexp = regexp.MustCompile(`^[[:blank:]]*# @requireBootstrap[[:blank:]]*$`)
 )
 
 const (
 	templateFieldFunctionName = "functionName"
 	templateFieldCode         = "code"
 	templateFieldRequires     = "requires"
+	templateFieldRequirements = "requirements"
 )
 
 type requiredFunctionNotFoundError struct {
@@ -34,13 +40,22 @@ type requiredFunctionNotFoundError struct {
 }
 
 func (e *requiredFunctionNotFoundError) Error() string {
-	msg := "required function not found in parsed code (you need to call it at least one time): " + e.functionName
+	msg := "required function not found in parsed code: " + e.functionName
 	if e.error != nil {
 		msg = fmt.Sprintf("%s - inner error:\n%v", msg, e.error)
 	}
 	return msg
 }
 
+type requireCycleError struct {
+	error
+	Cycle []string
+}
+
+func (e *requireCycleError) Error() string {
+	return "@require cycle detected: " + strings.Join(e.Cycle, " -> ")
+}
+
 const annotationRequireKind string = "require"
 
 type requireAnnotationProcessor struct {
@@ -48,11 +63,25 @@ type requireAnnotationProcessor struct {
 	compileContextData            *CompileContextData
 	checkRequirementsTemplateName string
 	requireTemplateName           string
+	requireBootstrapTemplateName  string
+	bootstrapPlaceholder          *templatePlaceholder
+}
+
+// Requirement is a function required using @require annotation
+// with the optional arguments the required function is called with
+type Requirement struct {
+	FunctionName string
+	Args         []string
+	// LoadedVariable is the variable set once the required function has been called,
+	// it depends on the arguments so that each set of arguments is tracked separately
+	LoadedVariable string
 }
 
 type requireAnnotation struct {
 	annotation
+	// requiredFunctions are the unique names of the required functions
 	requiredFunctions            []string
+	requirements                 []Requirement
 	isRequired                   bool
 	checkRequirementsCodeAdded   bool
 	codeAddedOnRequiredFunctions bool
@@ -98,8 +127,21 @@ func (annotationProcessor *requireAnnotationProcessor) Init(
 		}
 	}
 
+	requireBootstrapTemplateName, err := compileContextData.config.AnnotationsConfig.
+		GetStringValue("requireBootstrapTemplateName")
+	if err != nil {
+		return &customerrors.ValidationError{
+			InnerError: err,
+			Context:    "compileContextData.config.AnnotationsConfig",
+			FieldName:  "requireBootstrapTemplateName",
+			FieldValue: nil,
+		}
+	}
+
 	annotationProcessor.checkRequirementsTemplateName = checkRequirementsTemplateName
 	annotationProcessor.requireTemplateName = requireTemplateName
+	annotationProcessor.requireBootstrapTemplateName = requireBootstrapTemplateName
+	annotationProcessor.bootstrapPlaceholder = newTemplatePlaceholder(requireBootstrapRegexp)
 
 	return nil
 }
@@ -115,13 +157,17 @@ func (annotationProcessor *requireAnnotationProcessor) ParseFunction(
 	if logger.FancyHandleError(err) {
 		return err
 	}
-	annotation.requiredFunctions, functionStruct.SourceCode = extractRequiredFunctions(
+	annotation.requirements, functionStruct.SourceCode = extractRequirements(
 		functionStruct.SourceCode,
 	)
+	annotation.requiredFunctions = getRequiredFunctionNames(annotation.requirements)
 
 	if len(annotation.requiredFunctions) == 0 {
 		return nil
 	}
+	for _, requiredFunctionName := range annotation.requiredFunctions {
+		addRequiredFunction(compileContextData, requiredFunctionName)
+	}
 	err = isCodeContainsFunction(functionStruct.SourceCode, functionStruct.FunctionName)
 	if err != nil {
 		return err
@@ -133,6 +179,7 @@ func (annotationProcessor *requireAnnotationProcessor) ParseFunction(
 			templateFieldCode:         functionStruct.SourceCode,
 			templateFieldFunctionName: functionStruct.FunctionName,
 			templateFieldRequires:     annotation.requiredFunctions,
+			templateFieldRequirements: annotation.requirements,
 		},
 		*compileContextData.templateContextData,
 	)
@@ -145,29 +192,72 @@ func (annotationProcessor *requireAnnotationProcessor) ParseFunction(
 	return nil
 }
 
-func extractRequiredFunctions(code string) (requiredFunctions []string, newCode string) {
+// extractRequirements removes the @require annotations from the code, the first word of
+// the annotation is the required function, the following ones its arguments
+func extractRequirements(code string) (requirements []Requirement, newCode string) {
 	var newCodeBuffer bytes.Buffer
 	scanner := bufio.NewScanner(strings.NewReader(code))
-	requiredFunctions = []string{}
+	requirements = []Requirement{}
 	for scanner.Scan() {
 		line := scanner.Text()
 		matches := requireRegexp.FindStringSubmatch(line)
 		if matches != nil {
-			requireIndex := requireRegexp.SubexpIndex(annotationRequireKind)
-			requiredFunctions = append(requiredFunctions, strings.Trim(matches[requireIndex], " \t"))
+			fields := strings.Fields(matches[requireRegexp.SubexpIndex(annotationRequireKind)])
+			if len(fields) == 0 {
+				continue
+			}
+			requirements = append(requirements, newRequirement(fields[0], fields[1:]))
 		} else {
 			newCodeBuffer.Write([]byte(line))
 			newCodeBuffer.WriteByte('\n')
 		}
 	}
 	newCode = newCodeBuffer.String()
-	return requiredFunctions, newCode
+	return requirements, newCode
+}
+
+// addRequiredFunction adds the required function to the functions map if not already
+// referenced, so that it is loaded and resolved like any function used in the code
+func addRequiredFunction(compileContextData *CompileContextData, functionName string) {
+	if _, ok := compileContextData.functionsMap[functionName]; ok {
+		return
+	}
+	slog.Debug("Adding required function", logger.LogFieldFunc, functionName)
+	compileContextData.functionsMap[functionName] = createFunctionInfoStruct(
+		functionName, "", InsertPositionMiddle,
+	)
+}
+
+func newRequirement(functionName string, args []string) Requirement {
+	loadedVariable := "REQUIRE_FUNCTION_" + myTemplateFunctions.ToSnakeCase(functionName)
+	if len(args) > 0 {
+		argsChecksum := sha256.Sum256([]byte(strings.Join(args, "\x00")))
+		loadedVariable += "_" + strings.ToUpper(hex.EncodeToString(argsChecksum[:4]))
+	}
+	return Requirement{
+		FunctionName:   functionName,
+		Args:           args,
+		LoadedVariable: loadedVariable + "_LOADED",
+	}
+}
+
+func getRequiredFunctionNames(requirements []Requirement) []string {
+	requiredFunctions := []string{}
+	for _, requirement := range requirements {
+		if !slices.Contains(requiredFunctions, requirement.FunctionName) {
+			requiredFunctions = append(requiredFunctions, requirement.FunctionName)
+		}
+	}
+	return requiredFunctions
 }
 
 func (annotationProcessor *requireAnnotationProcessor) Process(
 	compileContextData *CompileContextData,
 ) error {
 	functionsMap := compileContextData.functionsMap
+	if _, cycle := sortRequirements(functionsMap); cycle != nil {
+		return &requireCycleError{nil, cycle}
+	}
 	functionNames := getSortedFunctionNamesFromMap(functionsMap)
 	sort.Strings(functionNames)
 	for _, functionName := range functionNames {
@@ -182,12 +272,62 @@ func (annotationProcessor *requireAnnotationProcessor) Process(
 	return nil
 }
 
+// sortRequirements returns the requirements of the included functions in topological order,
+// the requirements of a required function are listed before it, functions being visited
+// in alphabetical order and requirements in declaration order.
+// If the @require graph has a cycle, the functions of the first cycle found are returned,
+// the first function of the cycle being repeated at the end
+func sortRequirements(functionsMap map[string]functionInfoStruct) (requirements []Requirement, cycle []string) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	states := make(map[string]int)
+	path := []string{}
+	requirements = []Requirement{}
+	var visit func(functionName string) []string
+	visit = func(functionName string) []string {
+		switch states[functionName] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := slices.Clone(path[slices.Index(path, functionName):])
+			return append(cycle, functionName)
+		}
+		states[functionName] = visiting
+		path = append(path, functionName)
+		foundAnnotation, ok := functionsMap[functionName].AnnotationMap[annotationRequireKind].(requireAnnotation)
+		if ok {
+			for _, requirement := range foundAnnotation.requirements {
+				if cycle := visit(requirement.FunctionName); cycle != nil {
+					return cycle
+				}
+				if !slices.ContainsFunc(requirements, func(sortedRequirement Requirement) bool {
+					return sortedRequirement.LoadedVariable == requirement.LoadedVariable
+				}) {
+					requirements = append(requirements, requirement)
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		states[functionName] = visited
+		return nil
+	}
+	for _, functionName := range getSortedFunctionNamesFromMap(functionsMap) {
+		if cycle := visit(functionName); cycle != nil {
+			return nil, cycle
+		}
+	}
+	return requirements, nil
+}
+
 func (functionStruct *functionInfoStruct) getRequireAnnotation() (*requireAnnotation, error) {
 	annotationObj, ok := functionStruct.AnnotationMap[annotationRequireKind]
 	if annotationObj == nil || !ok {
 		newAnnotation := requireAnnotation{
 			annotation:                   annotation{},
 			requiredFunctions:            []string{},
+			requirements:                 []Requirement{},
 			isRequired:                   false,
 			checkRequirementsCodeAdded:   false,
 			codeAddedOnRequiredFunctions: false,
@@ -268,24 +408,36 @@ func (annotationProcessor *requireAnnotationProcessor) addRequireCode(
 }
 
 func isCodeContainsFunction(code string, functionName string) error {
-	matches := requiredFunctionRegex.FindAllStringSubmatch(code, -1)
 	slog.Debug("isCodeContainsFunction", templateFieldFunctionName, functionName)
-	if matches == nil {
-		slog.Error("isCodeContainsFunction no function regexp match")
-		return &requiredFunctionNotFoundError{nil, functionName}
-	}
-	bashFrameworkFunctionGroupIndex := requiredFunctionRegex.SubexpIndex("bashFrameworkFunction")
-	for _, match := range matches {
-		if match[bashFrameworkFunctionGroupIndex] == functionName {
-			return nil
-		}
+	if slices.Contains(getDefinedFunctionNames(code), functionName) {
+		return nil
 	}
 	slog.Error("isCodeContainsFunction function does not match", templateFieldFunctionName, functionName)
 	return &requiredFunctionNotFoundError{nil, functionName}
 }
 
-func (*requireAnnotationProcessor) PostProcess(
-	_ *CompileContextData, code string,
+// PostProcess renders the calls of all the requirements in dependency order in place of
+// the @requireBootstrap line of the template
+func (annotationProcessor *requireAnnotationProcessor) PostProcess(
+	compileContextData *CompileContextData, code string,
 ) (string, error) {
-	return code, nil
+	requirements, cycle := sortRequirements(compileContextData.functionsMap)
+	if cycle != nil {
+		return "", &requireCycleError{nil, cycle}
+	}
+	bootstrapCode := ""
+	if len(requirements) > 0 {
+		var err error
+		bootstrapCode, err = myTemplateFunctions.MustInclude(
+			annotationProcessor.requireBootstrapTemplateName,
+			map[string]any{
+				templateFieldRequirements: requirements,
+			},
+			*compileContextData.templateContextData,
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+	return annotationProcessor.bootstrapPlaceholder.insert(code, bootstrapCode), nil
 }
@@ -0,0 +1,294 @@
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	myTemplateFunctions "github.com/fchastanet/bash-compiler/internal/render"
+	"github.com/fchastanet/bash-compiler/internal/utils/customerrors"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+var (
+	requireCommandRegexp     = regexp.MustCompile(`^[[:blank:]]*# @requireCommand([[:blank:]].*)?$`)
+	requireCommandArgsRegexp = regexp.MustCompile(
+		`^[[:blank:]]+(?P<command>[^[:blank:]<>=]+)` +
+			`([[:blank:]]+(?P<operator>>=|<=|>|<|=)?(?P<version>[0-9]+(\.[0-9]+)*))?[[:blank:]]*$`,
+	)
+	// requireCommandsCheckRegexp is the line of the template replaced by the commands check
+	requireCommandsCheckRegexp = regexp.MustCompile(`^[[:blank:]]*# @requireCommandsCheck[[:blank:]]*$`)
+)
+
+const (
+	annotationRequireCommandKind string = "requireCommand"
+	templateFieldCommands               = "commands"
+	// requireCommandDefaultOperator is the operator used when only the version is provided
+	requireCommandDefaultOperator = ">="
+)
+
+type invalidRequireCommandError struct {
+	error
+	FunctionName string
+	Line         string
+}
+
+func (e *invalidRequireCommandError) Error() string {
+	return fmt.Sprintf(
+		"invalid @requireCommand annotation '%s' in %s, expected: # @requireCommand <command> [<operator><version>]",
+		strings.TrimSpace(e.Line), e.FunctionName,
+	)
+}
+
+// RequiredCommand is an external command required by the functions of a binary
+type RequiredCommand struct {
+	Name string `json:"name"`
+	// Operator and Version are empty if any version of the command is accepted
+	Operator string `json:"operator,omitempty"`
+	Version  string `json:"version,omitempty"`
+	// Functions are the functions requiring the command, main code being reported as main
+	Functions []string `json:"functions"`
+}
+
+// requireCommandAnnotationProcessor collects the commands required by the included
+// functions and renders a single check of all of them run by the main function after
+// the options parsing, so that every missing command is reported at once
+type requireCommandAnnotationProcessor struct {
+	annotationProcessor
+	requireCommandsTemplateName string
+	// mainCommands are the commands required outside of the functions
+	mainCommands     []RequiredCommand
+	checkPlaceholder *templatePlaceholder
+}
+
+type requireCommandAnnotation struct {
+	annotation
+	commands []RequiredCommand
+}
+
+func NewRequireCommandAnnotationProcessor() AnnotationProcessorInterface {
+	return &requireCommandAnnotationProcessor{} //nolint:exhaustruct // Check Init method
+}
+
+func (*requireCommandAnnotationProcessor) GetTitle() string {
+	return "RequireCommandAnnotationProcessor"
+}
+
+func (annotationProcessor *requireCommandAnnotationProcessor) Init(
+	compileContextData *CompileContextData,
+) error {
+	if compileContextData == nil {
+		return validationError("compileContextData", nil)
+	}
+	err := compileContextData.Validate()
+	if logger.FancyHandleError(err) {
+		return err
+	}
+	requireCommandsTemplateName, err := compileContextData.config.AnnotationsConfig.
+		GetStringValue("requireCommandsTemplateName")
+	if err != nil {
+		return &customerrors.ValidationError{
+			InnerError: err,
+			Context:    "compileContextData.config.AnnotationsConfig",
+			FieldName:  "requireCommandsTemplateName",
+			FieldValue: nil,
+		}
+	}
+	annotationProcessor.requireCommandsTemplateName = requireCommandsTemplateName
+	annotationProcessor.mainCommands = []RequiredCommand{}
+	annotationProcessor.checkPlaceholder = newTemplatePlaceholder(requireCommandsCheckRegexp)
+	return nil
+}
+
+// Reset keeps the commands of the main code as their annotations are removed
+// by the first post process
+func (*requireCommandAnnotationProcessor) Reset() {
+}
+
+// ParseFunction removes the @requireCommand annotations from the function code
+// and records the required commands
+func (*requireCommandAnnotationProcessor) ParseFunction(
+	_ *CompileContextData,
+	functionStruct *functionInfoStruct,
+) error {
+	commands, code, err := extractRequiredCommands(functionStruct.SourceCode, functionStruct.FunctionName)
+	if err != nil {
+		return err
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	functionStruct.SourceCode = code
+	functionStruct.AnnotationMap[annotationRequireCommandKind] = requireCommandAnnotation{
+		annotation: annotation{},
+		commands:   commands,
+	}
+	return nil
+}
+
+func extractRequiredCommands(code string, functionName string) (
+	commands []RequiredCommand, newCode string, err error,
+) {
+	var newCodeBuffer bytes.Buffer
+	commands = []RequiredCommand{}
+	scanner := bufio.NewScanner(strings.NewReader(code))
+	for scanner.Scan() {
+		line := scanner.Text()
+		matches := requireCommandRegexp.FindStringSubmatch(line)
+		if matches == nil {
+			newCodeBuffer.WriteString(line)
+			newCodeBuffer.WriteByte('\n')
+			continue
+		}
+		args := requireCommandArgsRegexp.FindStringSubmatch(matches[1])
+		if args == nil {
+			return nil, "", &invalidRequireCommandError{nil, functionName, line}
+		}
+		command := RequiredCommand{
+			Name:      args[requireCommandArgsRegexp.SubexpIndex("command")],
+			Operator:  args[requireCommandArgsRegexp.SubexpIndex("operator")],
+			Version:   args[requireCommandArgsRegexp.SubexpIndex("version")],
+			Functions: []string{functionName},
+		}
+		if command.Version != "" && command.Operator == "" {
+			command.Operator = requireCommandDefaultOperator
+		}
+		commands = append(commands, command)
+	}
+	return commands, newCodeBuffer.String(), nil
+}
+
+func (*requireCommandAnnotationProcessor) Process(_ *CompileContextData) error {
+	return nil
+}
+
+// PostProcess removes the @requireCommand annotations of the main code and
+// renders the check of all the required commands in place of the
+// @requireCommandsCheck line of the template
+func (annotationProcessor *requireCommandAnnotationProcessor) PostProcess(
+	compileContextData *CompileContextData, code string,
+) (string, error) {
+	mainCommands, code, err := extractRequiredCommands(code, "main")
+	if err != nil {
+		return "", err
+	}
+	annotationProcessor.mainCommands = mergeRequiredCommands(annotationProcessor.mainCommands, mainCommands)
+	commands := mergeRequiredCommands([]RequiredCommand{}, annotationProcessor.mainCommands)
+	for _, functionName := range getSortedFunctionNamesFromMap(compileContextData.functionsMap) {
+		functionInfo := compileContextData.functionsMap[functionName]
+		foundAnnotation, ok := functionInfo.AnnotationMap[annotationRequireCommandKind].(requireCommandAnnotation)
+		if ok {
+			commands = mergeRequiredCommands(commands, foundAnnotation.commands)
+		}
+	}
+
+	checkCode := ""
+	if len(commands) > 0 {
+		checkCode, err = myTemplateFunctions.MustInclude(
+			annotationProcessor.requireCommandsTemplateName,
+			map[string]any{
+				templateFieldCommands: commands,
+			},
+			*compileContextData.templateContextData,
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+	return annotationProcessor.checkPlaceholder.insert(code, checkCode), nil
+}
+
+// mergeRequiredCommands adds the commands to the list, the functions of the same
+// command and version requirement are merged, the list is sorted by command name
+func mergeRequiredCommands(commands []RequiredCommand, newCommands []RequiredCommand) []RequiredCommand {
+	for _, newCommand := range newCommands {
+		merged := false
+		for index, command := range commands {
+			if command.Name != newCommand.Name ||
+				command.Operator != newCommand.Operator || command.Version != newCommand.Version {
+				continue
+			}
+			for _, functionName := range newCommand.Functions {
+				if !slices.Contains(command.Functions, functionName) {
+					commands[index].Functions = append(commands[index].Functions, functionName)
+				}
+			}
+			merged = true
+			break
+		}
+		if !merged {
+			commands = append(commands, RequiredCommand{
+				Name:      newCommand.Name,
+				Operator:  newCommand.Operator,
+				Version:   newCommand.Version,
+				Functions: append([]string{}, newCommand.Functions...),
+			})
+		}
+	}
+	sort.SliceStable(commands, func(i, j int) bool {
+		if commands[i].Name != commands[j].Name {
+			return commands[i].Name < commands[j].Name
+		}
+		return commands[i].Operator+commands[i].Version < commands[j].Operator+commands[j].Version
+	})
+	return commands
+}
+
+// templatePlaceholder is a line of the binary template replaced by generated code
+type templatePlaceholder struct {
+	regexp *regexp.Regexp
+	// found is true once the placeholder has been replaced, the code being post processed several times
+	found bool
+}
+
+func newTemplatePlaceholder(placeholderRegexp *regexp.Regexp) *templatePlaceholder {
+	return &templatePlaceholder{regexp: placeholderRegexp, found: false}
+}
+
+// insert replaces the placeholder by the generated code, a warning is logged
+// if there is some code to insert but the template does not provide the placeholder
+func (placeholder *templatePlaceholder) insert(code string, generatedCode string) string {
+	code, found := insertGeneratedCode(code, placeholder.regexp, generatedCode)
+	placeholder.found = placeholder.found || found
+	if generatedCode != "" && !placeholder.found {
+		slog.Warn("Generated code not inserted, placeholder not found", "placeholder", placeholder.regexp.String())
+	}
+	return code
+}
+
+// insertGeneratedCode replaces the lines matching placeholderRegexp by the generated code
+// indented like the placeholder line. The placeholder lines are consumed so that the code
+// is inserted only once even if the code is post processed again
+func insertGeneratedCode(
+	code string, placeholderRegexp *regexp.Regexp, generatedCode string,
+) (newCode string, placeholderFound bool) {
+	var bufferOutput bytes.Buffer
+	for _, line := range strings.SplitAfter(code, "\n") {
+		if placeholderRegexp.MatchString(strings.TrimSuffix(line, "\n")) {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			bufferOutput.WriteString(indentCode(generatedCode, indent))
+			placeholderFound = true
+			continue
+		}
+		bufferOutput.WriteString(line)
+	}
+	return bufferOutput.String(), placeholderFound
+}
+
+func indentCode(code string, indent string) string {
+	if indent == "" {
+		return code
+	}
+	lines := strings.SplitAfter(code, "\n")
+	for index, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[index] = indent + line
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,148 @@
+package compiler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/model"
+	"github.com/fchastanet/bash-compiler/internal/render"
+	"github.com/fchastanet/bash-compiler/internal/utils/structures"
+	"gotest.tools/v3/assert"
+)
+
+// getRequireCommandCompileContextData renders the template as "check <commands>"
+func getRequireCommandCompileContextData(annotationsConfig structures.Dictionary) *CompileContextData {
+	return getCompileContextData(
+		&model.CompilerConfig{AnnotationsConfig: annotationsConfig}, //nolint:exhaustruct // test
+		func(templateContextData *render.TemplateContextData, _ string) (string, error) {
+			data, ok := templateContextData.Data.(map[string]any)
+			if !ok {
+				return "", validationError("invalid Data", templateContextData.Data)
+			}
+			return fmt.Sprintf("check %v", data[templateFieldCommands]), nil
+		},
+	)
+}
+
+func TestRequireCommandInit(t *testing.T) {
+	t.Run("compileContextData nil", func(t *testing.T) {
+		err := NewRequireCommandAnnotationProcessor().Init(nil)
+		assert.Error(t, err, "validation failed invalid value : "+
+			"context annotationEmbed field compileContextData value <nil>")
+	})
+	t.Run("missing template name", func(t *testing.T) {
+		err := NewRequireCommandAnnotationProcessor().Init(
+			getRequireCommandCompileContextData(structures.Dictionary{}),
+		)
+		assert.Error(t, err, "validation failed invalid value : context compileContextData.config.AnnotationsConfig "+
+			"field requireCommandsTemplateName value <nil> inner error missing key: requireCommandsTemplateName")
+	})
+}
+
+func TestRequireCommandParseFunction(t *testing.T) {
+	processor := NewRequireCommandAnnotationProcessor()
+	t.Run("no annotation", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName:  "My::func",
+			SourceCode:    "# @requireCommands jq\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.NilError(t, processor.ParseFunction(nil, functionStruct))
+		assert.Equal(t, "# @requireCommands jq\nMy::func() { :; }\n", functionStruct.SourceCode)
+		assert.Equal(t, 0, len(functionStruct.AnnotationMap))
+	})
+	t.Run("annotations", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName: "My::func",
+			SourceCode: "# @requireCommand jq >=1.6\n# @requireCommand curl\n" +
+				"  # @requireCommand git 2.30  \nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.NilError(t, processor.ParseFunction(nil, functionStruct))
+		assert.Equal(t, "My::func() { :; }\n", functionStruct.SourceCode)
+		foundAnnotation, ok := functionStruct.AnnotationMap[annotationRequireCommandKind].(requireCommandAnnotation)
+		assert.Assert(t, ok)
+		assert.DeepEqual(t, []RequiredCommand{
+			{Name: "jq", Operator: ">=", Version: "1.6", Functions: []string{"My::func"}},
+			{Name: "curl", Operator: "", Version: "", Functions: []string{"My::func"}},
+			{Name: "git", Operator: ">=", Version: "2.30", Functions: []string{"My::func"}},
+		}, foundAnnotation.commands)
+	})
+	t.Run("invalid annotation", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName:  "My::func",
+			SourceCode:    "# @requireCommand jq latest\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.Error(t, processor.ParseFunction(nil, functionStruct),
+			"invalid @requireCommand annotation '# @requireCommand jq latest' in My::func, "+
+				"expected: # @requireCommand <command> [<operator><version>]")
+	})
+}
+
+func TestMergeRequiredCommands(t *testing.T) {
+	commands := mergeRequiredCommands([]RequiredCommand{}, []RequiredCommand{
+		{Name: "jq", Operator: ">=", Version: "1.6", Functions: []string{"B::func"}},
+		{Name: "curl", Operator: "", Version: "", Functions: []string{"B::func"}},
+	})
+	commands = mergeRequiredCommands(commands, []RequiredCommand{
+		{Name: "jq", Operator: ">=", Version: "1.6", Functions: []string{"A::func"}},
+		{Name: "jq", Operator: "", Version: "", Functions: []string{"A::func"}},
+		{Name: "curl", Operator: "", Version: "", Functions: []string{"B::func"}},
+	})
+	assert.DeepEqual(t, []RequiredCommand{
+		{Name: "curl", Operator: "", Version: "", Functions: []string{"B::func"}},
+		{Name: "jq", Operator: "", Version: "", Functions: []string{"A::func"}},
+		{Name: "jq", Operator: ">=", Version: "1.6", Functions: []string{"B::func", "A::func"}},
+	}, commands)
+}
+
+func TestRequireCommandPostProcess(t *testing.T) {
+	newProcessor := func(t *testing.T) (AnnotationProcessorInterface, *CompileContextData) {
+		t.Helper()
+		compileContextData := getRequireCommandCompileContextData(structures.Dictionary{
+			"requireCommandsTemplateName": "requireCommands",
+		})
+		compileContextData.functionsMap["My::func"] = functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName: "My::func",
+			AnnotationMap: map[string]any{
+				annotationRequireCommandKind: requireCommandAnnotation{
+					annotation: annotation{},
+					commands:   []RequiredCommand{{Name: "jq", Operator: "", Version: "", Functions: []string{"My::func"}}},
+				},
+			},
+		}
+		processor := NewRequireCommandAnnotationProcessor()
+		assert.NilError(t, processor.Init(compileContextData))
+		return processor, compileContextData
+	}
+
+	t.Run("check inserted in place of @requireCommandsCheck", func(t *testing.T) {
+		processor, compileContextData := newProcessor(t)
+		code, err := processor.PostProcess(
+			compileContextData,
+			"#!/bin/bash\n# FUNCTIONS\n# @requireCommandsCheck\nmain() {\n  # @requireCommand curl\n}\n",
+		)
+		assert.NilError(t, err)
+		expectedCode := "#!/bin/bash\n# FUNCTIONS\ncheck [{curl   [main]} {jq   [My::func]}]\nmain() {\n}\n"
+		assert.Equal(t, expectedCode, code)
+
+		// already inserted check is kept as is
+		code, err = processor.PostProcess(compileContextData, code)
+		assert.NilError(t, err)
+		assert.Equal(t, expectedCode, code)
+	})
+	t.Run("no placeholder", func(t *testing.T) {
+		processor, compileContextData := newProcessor(t)
+		code, err := processor.PostProcess(compileContextData, "#!/bin/bash\n# FUNCTIONS\nmain\n")
+		assert.NilError(t, err)
+		assert.Equal(t, "#!/bin/bash\n# FUNCTIONS\nmain\n", code)
+	})
+	t.Run("no required command", func(t *testing.T) {
+		processor, compileContextData := newProcessor(t)
+		delete(compileContextData.functionsMap, "My::func")
+		code, err := processor.PostProcess(compileContextData, "# FUNCTIONS\n# @requireCommandsCheck\nmain\n")
+		assert.NilError(t, err)
+		assert.Equal(t, "# FUNCTIONS\nmain\n", code)
+	})
+}
@@ -0,0 +1,249 @@
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	myTemplateFunctions "github.com/fchastanet/bash-compiler/internal/render"
+	"github.com/fchastanet/bash-compiler/internal/utils/customerrors"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+var (
+	requireEnvRegexp     = regexp.MustCompile(`^[[:blank:]]*# @requireEnv([[:blank:]].*)?$`)
+	requireEnvArgsRegexp = regexp.MustCompile(
+		`^[[:blank:]]+(?P<variable>[A-Za-z_][A-Za-z0-9_]*)([[:blank:]]+(?P<default>.*))?$`,
+	)
+	// requireEnvCheckRegexp is the line of the template replaced by the environment variables check
+	requireEnvCheckRegexp = regexp.MustCompile(`^[[:blank:]]*# @requireEnvCheck[[:blank:]]*$`)
+	// requireEnvHelpRegexp is the line of the help template replaced by the environment section
+	requireEnvHelpRegexp = regexp.MustCompile(`^[[:blank:]]*# @requireEnvHelp[[:blank:]]*$`)
+)
+
+const (
+	annotationRequireEnvKind string = "requireEnv"
+	templateFieldVariables          = "variables"
+)
+
+type invalidRequireEnvError struct {
+	error
+	FunctionName string
+	Line         string
+}
+
+func (e *invalidRequireEnvError) Error() string {
+	return fmt.Sprintf(
+		"invalid @requireEnv annotation '%s' in %s, expected: # @requireEnv <variable> [<default value>]",
+		strings.TrimSpace(e.Line), e.FunctionName,
+	)
+}
+
+// RequiredEnvVariable is an environment variable required by the functions of a binary
+type RequiredEnvVariable struct {
+	Name string `json:"name"`
+	// Default is the value set if the variable is empty, the variable is mandatory if not provided
+	Default string `json:"default,omitempty"`
+	// Functions are the functions requiring the variable, main code being reported as main
+	Functions []string `json:"functions"`
+}
+
+// requireEnvAnnotationProcessor collects the environment variables required by the
+// included functions, it renders a single check of all of them at the top of the binary
+// and the environment section of the commands help
+type requireEnvAnnotationProcessor struct {
+	annotationProcessor
+	requireEnvTemplateName     string
+	requireEnvHelpTemplateName string
+	// mainVariables are the variables required outside of the functions
+	mainVariables    []RequiredEnvVariable
+	checkPlaceholder *templatePlaceholder
+}
+
+type requireEnvAnnotation struct {
+	annotation
+	variables []RequiredEnvVariable
+}
+
+func NewRequireEnvAnnotationProcessor() AnnotationProcessorInterface {
+	return &requireEnvAnnotationProcessor{} //nolint:exhaustruct // Check Init method
+}
+
+func (*requireEnvAnnotationProcessor) GetTitle() string {
+	return "RequireEnvAnnotationProcessor"
+}
+
+func (annotationProcessor *requireEnvAnnotationProcessor) Init(
+	compileContextData *CompileContextData,
+) error {
+	if compileContextData == nil {
+		return validationError("compileContextData", nil)
+	}
+	err := compileContextData.Validate()
+	if logger.FancyHandleError(err) {
+		return err
+	}
+	requireEnvTemplateName, err := compileContextData.config.AnnotationsConfig.
+		GetStringValue("requireEnvTemplateName")
+	if err != nil {
+		return &customerrors.ValidationError{
+			InnerError: err,
+			Context:    "compileContextData.config.AnnotationsConfig",
+			FieldName:  "requireEnvTemplateName",
+			FieldValue: nil,
+		}
+	}
+	requireEnvHelpTemplateName, err := compileContextData.config.AnnotationsConfig.
+		GetStringValue("requireEnvHelpTemplateName")
+	if err != nil {
+		return &customerrors.ValidationError{
+			InnerError: err,
+			Context:    "compileContextData.config.AnnotationsConfig",
+			FieldName:  "requireEnvHelpTemplateName",
+			FieldValue: nil,
+		}
+	}
+	annotationProcessor.requireEnvTemplateName = requireEnvTemplateName
+	annotationProcessor.requireEnvHelpTemplateName = requireEnvHelpTemplateName
+	annotationProcessor.mainVariables = []RequiredEnvVariable{}
+	annotationProcessor.checkPlaceholder = newTemplatePlaceholder(requireEnvCheckRegexp)
+	return nil
+}
+
+// Reset keeps the variables of the main code as their annotations are removed
+// by the first post process
+func (*requireEnvAnnotationProcessor) Reset() {
+}
+
+// ParseFunction removes the @requireEnv annotations from the function code
+// and records the required variables
+func (*requireEnvAnnotationProcessor) ParseFunction(
+	_ *CompileContextData,
+	functionStruct *functionInfoStruct,
+) error {
+	variables, code, err := extractRequiredEnvVariables(functionStruct.SourceCode, functionStruct.FunctionName)
+	if err != nil {
+		return err
+	}
+	if len(variables) == 0 {
+		return nil
+	}
+	functionStruct.SourceCode = code
+	functionStruct.AnnotationMap[annotationRequireEnvKind] = requireEnvAnnotation{
+		annotation: annotation{},
+		variables:  variables,
+	}
+	return nil
+}
+
+func extractRequiredEnvVariables(code string, functionName string) (
+	variables []RequiredEnvVariable, newCode string, err error,
+) {
+	var newCodeBuffer bytes.Buffer
+	variables = []RequiredEnvVariable{}
+	scanner := bufio.NewScanner(strings.NewReader(code))
+	for scanner.Scan() {
+		line := scanner.Text()
+		matches := requireEnvRegexp.FindStringSubmatch(line)
+		if matches == nil {
+			newCodeBuffer.WriteString(line)
+			newCodeBuffer.WriteByte('\n')
+			continue
+		}
+		args := requireEnvArgsRegexp.FindStringSubmatch(matches[1])
+		if args == nil {
+			return nil, "", &invalidRequireEnvError{nil, functionName, line}
+		}
+		variables = append(variables, RequiredEnvVariable{
+			Name:      args[requireEnvArgsRegexp.SubexpIndex("variable")],
+			Default:   strings.TrimSpace(args[requireEnvArgsRegexp.SubexpIndex("default")]),
+			Functions: []string{functionName},
+		})
+	}
+	return variables, newCodeBuffer.String(), nil
+}
+
+func (*requireEnvAnnotationProcessor) Process(_ *CompileContextData) error {
+	return nil
+}
+
+// PostProcess removes the @requireEnv annotations of the main code, renders the check of
+// all the required variables in place of the @requireEnvCheck line of the template
+// and the environment section of the help
+// in place of the @requireEnvHelp lines
+func (annotationProcessor *requireEnvAnnotationProcessor) PostProcess(
+	compileContextData *CompileContextData, code string,
+) (string, error) {
+	mainVariables, code, err := extractRequiredEnvVariables(code, "main")
+	if err != nil {
+		return "", err
+	}
+	annotationProcessor.mainVariables = mergeRequiredEnvVariables(annotationProcessor.mainVariables, mainVariables)
+	variables := mergeRequiredEnvVariables([]RequiredEnvVariable{}, annotationProcessor.mainVariables)
+	for _, functionName := range getSortedFunctionNamesFromMap(compileContextData.functionsMap) {
+		functionInfo := compileContextData.functionsMap[functionName]
+		foundAnnotation, ok := functionInfo.AnnotationMap[annotationRequireEnvKind].(requireEnvAnnotation)
+		if ok {
+			variables = mergeRequiredEnvVariables(variables, foundAnnotation.variables)
+		}
+	}
+
+	checkCode := ""
+	helpCode := ""
+	if len(variables) > 0 {
+		data := map[string]any{
+			templateFieldVariables: variables,
+		}
+		checkCode, err = myTemplateFunctions.MustInclude(
+			annotationProcessor.requireEnvTemplateName, data, *compileContextData.templateContextData,
+		)
+		if err != nil {
+			return "", err
+		}
+		helpCode, err = myTemplateFunctions.MustInclude(
+			annotationProcessor.requireEnvHelpTemplateName, data, *compileContextData.templateContextData,
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+	code, _ = insertGeneratedCode(code, requireEnvHelpRegexp, helpCode)
+	return annotationProcessor.checkPlaceholder.insert(code, checkCode), nil
+}
+
+// mergeRequiredEnvVariables adds the variables to the list, the functions requiring the same
+// variable are merged, the first default value provided (main code first then functions
+// sorted by name) is kept, the list is sorted by name
+func mergeRequiredEnvVariables(
+	variables []RequiredEnvVariable, newVariables []RequiredEnvVariable,
+) []RequiredEnvVariable {
+	for _, newVariable := range newVariables {
+		index := slices.IndexFunc(variables, func(variable RequiredEnvVariable) bool {
+			return variable.Name == newVariable.Name
+		})
+		if index == -1 {
+			variables = append(variables, RequiredEnvVariable{
+				Name:      newVariable.Name,
+				Default:   newVariable.Default,
+				Functions: append([]string{}, newVariable.Functions...),
+			})
+			continue
+		}
+		if variables[index].Default == "" {
+			variables[index].Default = newVariable.Default
+		}
+		for _, functionName := range newVariable.Functions {
+			if !slices.Contains(variables[index].Functions, functionName) {
+				variables[index].Functions = append(variables[index].Functions, functionName)
+			}
+		}
+	}
+	sort.SliceStable(variables, func(i, j int) bool {
+		return variables[i].Name < variables[j].Name
+	})
+	return variables
+}
@@ -0,0 +1,127 @@
+package compiler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/model"
+	"github.com/fchastanet/bash-compiler/internal/render"
+	"github.com/fchastanet/bash-compiler/internal/utils/structures"
+	"gotest.tools/v3/assert"
+)
+
+// getRequireEnvCompileContextData renders the templates as "<templateName> <variables>"
+func getRequireEnvCompileContextData(annotationsConfig structures.Dictionary) *CompileContextData {
+	return getCompileContextData(
+		&model.CompilerConfig{AnnotationsConfig: annotationsConfig}, //nolint:exhaustruct // test
+		func(templateContextData *render.TemplateContextData, templateName string) (string, error) {
+			data, ok := templateContextData.Data.(map[string]any)
+			if !ok {
+				return "", validationError("invalid Data", templateContextData.Data)
+			}
+			return fmt.Sprintf("%s %v", templateName, data[templateFieldVariables]), nil
+		},
+	)
+}
+
+func TestRequireEnvInit(t *testing.T) {
+	t.Run("compileContextData nil", func(t *testing.T) {
+		err := NewRequireEnvAnnotationProcessor().Init(nil)
+		assert.Error(t, err, "validation failed invalid value : "+
+			"context annotationEmbed field compileContextData value <nil>")
+	})
+	t.Run("missing template name", func(t *testing.T) {
+		err := NewRequireEnvAnnotationProcessor().Init(
+			getRequireEnvCompileContextData(structures.Dictionary{}),
+		)
+		assert.Error(t, err, "validation failed invalid value : context compileContextData.config.AnnotationsConfig "+
+			"field requireEnvTemplateName value <nil> inner error missing key: requireEnvTemplateName")
+	})
+	t.Run("missing help template name", func(t *testing.T) {
+		err := NewRequireEnvAnnotationProcessor().Init(
+			getRequireEnvCompileContextData(structures.Dictionary{"requireEnvTemplateName": "requireEnv"}),
+		)
+		assert.Error(t, err, "validation failed invalid value : context compileContextData.config.AnnotationsConfig "+
+			"field requireEnvHelpTemplateName value <nil> inner error missing key: requireEnvHelpTemplateName")
+	})
+}
+
+func TestRequireEnvParseFunction(t *testing.T) {
+	processor := NewRequireEnvAnnotationProcessor()
+	t.Run("annotations", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName: "My::func",
+			SourceCode: "# @requireEnv FRAMEWORK_ROOT_DIR\n" +
+				"# @requireEnv LOG_LEVEL  ${DEFAULT_LEVEL:-INFO} \nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.NilError(t, processor.ParseFunction(nil, functionStruct))
+		assert.Equal(t, "My::func() { :; }\n", functionStruct.SourceCode)
+		foundAnnotation, ok := functionStruct.AnnotationMap[annotationRequireEnvKind].(requireEnvAnnotation)
+		assert.Assert(t, ok)
+		assert.DeepEqual(t, []RequiredEnvVariable{
+			{Name: "FRAMEWORK_ROOT_DIR", Default: "", Functions: []string{"My::func"}},
+			{Name: "LOG_LEVEL", Default: "${DEFAULT_LEVEL:-INFO}", Functions: []string{"My::func"}},
+		}, foundAnnotation.variables)
+	})
+	t.Run("invalid annotation", func(t *testing.T) {
+		functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
+			FunctionName:  "My::func",
+			SourceCode:    "# @requireEnv 1_INVALID\nMy::func() { :; }\n",
+			AnnotationMap: make(map[string]any),
+		}
+		assert.Error(t, processor.ParseFunction(nil, functionStruct),
+			"invalid @requireEnv annotation '# @requireEnv 1_INVALID' in My::func, "+
+				"expected: # @requireEnv <variable> [<default value>]")
+	})
+}
+
+func TestMergeRequiredEnvVariables(t *testing.T) {
+	variables := mergeRequiredEnvVariables([]RequiredEnvVariable{}, []RequiredEnvVariable{
+		{Name: "LOG_LEVEL", Default: "", Functions: []string{"B::func"}},
+		{Name: "HOME_DIR", Default: "/home", Functions: []string{"B::func"}},
+	})
+	variables = mergeRequiredEnvVariables(variables, []RequiredEnvVariable{
+		{Name: "LOG_LEVEL", Default: "INFO", Functions: []string{"A::func"}},
+		{Name: "HOME_DIR", Default: "/root", Functions: []string{"A::func"}},
+	})
+	assert.DeepEqual(t, []RequiredEnvVariable{
+		{Name: "HOME_DIR", Default: "/home", Functions: []string{"B::func", "A::func"}},
+		{Name: "LOG_LEVEL", Default: "INFO", Functions: []string{"B::func", "A::func"}},
+	}, variables)
+}
+
+func TestRequireEnvPostProcess(t *testing.T) {
+	compileContextData := getRequireEnvCompileContextData(structures.Dictionary{
+		"requireEnvTemplateName":     "requireEnv",
+		"requireEnvHelpTemplateName": "requireEnvHelp",
+	})
+	compileContextData.functionsMap["My::func"] = functionInfoStruct{ //nolint:exhaustruct // test
+		FunctionName: "My::func",
+		AnnotationMap: map[string]any{
+			annotationRequireEnvKind: requireEnvAnnotation{
+				annotation: annotation{},
+				variables:  []RequiredEnvVariable{{Name: "LOG_LEVEL", Default: "INFO", Functions: []string{"My::func"}}},
+			},
+		},
+	}
+	processor := NewRequireEnvAnnotationProcessor()
+	assert.NilError(t, processor.Init(compileContextData))
+
+	code, err := processor.PostProcess(
+		compileContextData,
+		"# FUNCTIONS\n# @requireEnvCheck\nhelp() {\n  # @requireEnvHelp\n}\nmain() {\n  # @requireEnv ROOT_DIR\n}\n",
+	)
+	assert.NilError(t, err)
+	expectedCode := "# FUNCTIONS\n" +
+		"requireEnv [{LOG_LEVEL INFO [My::func]} {ROOT_DIR  [main]}]\n" +
+		"help() {\n" +
+		"  requireEnvHelp [{LOG_LEVEL INFO [My::func]} {ROOT_DIR  [main]}]\n" +
+		"}\nmain() {\n}\n"
+	assert.Equal(t, expectedCode, code)
+
+	// already inserted code is kept as is
+	code, err = processor.PostProcess(compileContextData, code)
+	assert.NilError(t, err)
+	assert.Equal(t, expectedCode, code)
+}
@@ -1,8 +1,14 @@
 package compiler
 
 import (
+	"bytes"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/fchastanet/bash-compiler/internal/model"
@@ -107,6 +113,7 @@ func getValidRequireProcessor(t *testing.T) AnnotationProcessorInterface {
 			AnnotationsConfig: structures.Dictionary{
 				"checkRequirementsTemplateName": "templateName",
 				"requireTemplateName":           "template",
+				"requireBootstrapTemplateName":  "bootstrapTemplate",
 			},
 		},
 		make(map[string]functionInfoStruct),
@@ -116,6 +123,25 @@ func getValidRequireProcessor(t *testing.T) AnnotationProcessorInterface {
 	return requireProcessor
 }
 
+func TestRequireInitInvalidCompileContextDataMissingRequireBootstrapTemplate(t *testing.T) {
+	requireProcessor := NewRequireAnnotationProcessor()
+	err := requireProcessor.Init(&CompileContextData{
+		compileContext:      &CompileContext{},             //nolint:exhaustruct // test
+		templateContextData: &render.TemplateContextData{}, //nolint:exhaustruct // test
+		config: &model.CompilerConfig{ //nolint:exhaustruct // test
+			AnnotationsConfig: structures.Dictionary{
+				"checkRequirementsTemplateName": "checkRequirementsTemplate",
+				"requireTemplateName":           "requireTemplate",
+			},
+		},
+		functionsMap:          make(map[string]functionInfoStruct),
+		ignoreFunctionsRegexp: []*regexp.Regexp{},
+	})
+	assert.Error(t, err, "validation failed invalid value : "+
+		"context compileContextData.config.AnnotationsConfig field requireBootstrapTemplateName value <nil> "+
+		"inner error missing key: requireBootstrapTemplateName")
+}
+
 func TestRequireParseFunctionNoRequiredFunction(t *testing.T) {
 	requireProcessor := getValidRequireProcessor(t)
 	functionStruct := &functionInfoStruct{ //nolint:exhaustruct // test
@@ -137,8 +163,10 @@ func TestRequireParseFunctionWithARequiredFunction(t *testing.T) {
 		FunctionName:  "Function::myFunction",
 		SourceCode:    "# @require MyRequired::function\nfunction Function::myFunction() {\n:;\n}",
 	}
+	functionsMap := make(map[string]functionInfoStruct)
 	err := requireProcessor.ParseFunction(
 		&CompileContextData{ //nolint:exhaustruct // test
+			functionsMap: functionsMap,
 			templateContextData: &render.TemplateContextData{ //nolint:exhaustruct // test
 				TemplateContext: &templateContextMock{
 					templateContextRenderFunc: func(
@@ -161,6 +189,240 @@ func TestRequireParseFunctionWithARequiredFunction(t *testing.T) {
 	)
 	assert.Equal(t, nil, err)
 	golden.Assert(t, functionStruct.SourceCode, "expectedTestRequireParseFunctionWithARequiredFunction.txt")
+	// required function is added to be loaded even if not referenced in the code
+	requiredFunction, ok := functionsMap["MyRequired::function"]
+	assert.Assert(t, ok)
+	assert.Equal(t, "", requiredFunction.SrcFile)
+	assert.Equal(t, false, requiredFunction.SourceCodeLoaded)
+}
+
+func TestExtractRequirements(t *testing.T) {
+	requirements, code := extractRequirements(
+		"# @require Env::requireLoad\n" +
+			"# @require Linux::requireCommand  jq curl \n" +
+			"# @require Version::requireMin bash 5.1\n" +
+			"# @require Linux::requireCommand jq\n" +
+			"My::func() { :; }\n",
+	)
+	assert.Equal(t, "My::func() { :; }\n", code)
+	assert.DeepEqual(t, []Requirement{
+		{
+			FunctionName:   "Env::requireLoad",
+			Args:           []string{},
+			LoadedVariable: "REQUIRE_FUNCTION_ENV_REQUIRE_LOAD_LOADED",
+		},
+		{
+			FunctionName:   "Linux::requireCommand",
+			Args:           []string{"jq", "curl"},
+			LoadedVariable: "REQUIRE_FUNCTION_LINUX_REQUIRE_COMMAND_181624A2_LOADED",
+		},
+		{
+			FunctionName:   "Version::requireMin",
+			Args:           []string{"bash", "5.1"},
+			LoadedVariable: "REQUIRE_FUNCTION_VERSION_REQUIRE_MIN_309C60F8_LOADED",
+		},
+		{
+			FunctionName:   "Linux::requireCommand",
+			Args:           []string{"jq"},
+			LoadedVariable: "REQUIRE_FUNCTION_LINUX_REQUIRE_COMMAND_C84D384F_LOADED",
+		},
+	}, requirements)
+	assert.DeepEqual(t,
+		[]string{"Env::requireLoad", "Linux::requireCommand", "Version::requireMin"},
+		getRequiredFunctionNames(requirements),
+	)
+}
+
+func TestRequirePostProcessBootstrap(t *testing.T) {
+	compileContextData := newRequireCompileContextData(t, t.TempDir())
+	requireProcessor := compileContextData.compileContext.annotationProcessors[0]
+	requirements := []Requirement{newRequirement("Env::requireLoad", []string{})}
+	compileContextData.functionsMap["Log::requireLoad"] = functionInfoStruct{ //nolint:exhaustruct // test
+		FunctionName: "Log::requireLoad",
+		AnnotationMap: map[string]any{
+			annotationRequireKind: requireAnnotation{ //nolint:exhaustruct // test
+				requiredFunctions: getRequiredFunctionNames(requirements),
+				requirements:      requirements,
+			},
+		},
+	}
+
+	code, err := requireProcessor.PostProcess(compileContextData, "# FUNCTIONS\nmain() {\n  # @requireBootstrap\n}\n")
+	assert.NilError(t, err)
+	expectedCode := "# FUNCTIONS\nmain() {\n  # bootstrap [Env::requireLoad]\n}\n"
+	assert.Equal(t, expectedCode, code)
+
+	// already inserted bootstrap is kept as is
+	code, err = requireProcessor.PostProcess(compileContextData, code)
+	assert.NilError(t, err)
+	assert.Equal(t, expectedCode, code)
+}
+
+func TestSortRequirements(t *testing.T) {
+	newFunction := func(requirements ...Requirement) functionInfoStruct {
+		return functionInfoStruct{ //nolint:exhaustruct // test
+			AnnotationMap: map[string]any{
+				annotationRequireKind: requireAnnotation{ //nolint:exhaustruct // test
+					requiredFunctions: getRequiredFunctionNames(requirements),
+					requirements:      requirements,
+				},
+			},
+		}
+	}
+	requireA := newRequirement("A::requireA", []string{})
+	requireB := newRequirement("B::requireB", []string{})
+	requireCJq := newRequirement("C::requireC", []string{"jq"})
+	requireCCurl := newRequirement("C::requireC", []string{"curl"})
+	t.Run("topological order", func(t *testing.T) {
+		requirements, cycle := sortRequirements(map[string]functionInfoStruct{
+			"A::func":     newFunction(requireB, requireCJq),
+			"A::requireA": newFunction(requireCCurl),
+			"B::requireB": newFunction(requireA, requireCJq),
+			"C::requireC": newFunction(),
+			"D::noAnno":   {}, //nolint:exhaustruct // test
+		})
+		assert.Assert(t, cycle == nil)
+		assert.DeepEqual(t, []Requirement{requireCCurl, requireA, requireCJq, requireB}, requirements)
+	})
+	t.Run("self require", func(t *testing.T) {
+		requirements, cycle := sortRequirements(map[string]functionInfoStruct{
+			"A::requireA": newFunction(requireA),
+		})
+		assert.Assert(t, requirements == nil)
+		assert.DeepEqual(t, []string{"A::requireA", "A::requireA"}, cycle)
+	})
+	t.Run("cycle", func(t *testing.T) {
+		_, cycle := sortRequirements(map[string]functionInfoStruct{
+			"A::func":     newFunction(requireA),
+			"A::requireA": newFunction(requireB),
+			"B::requireB": newFunction(requireCJq),
+			"C::requireC": newFunction(requireA),
+		})
+		assert.DeepEqual(t, []string{"A::requireA", "B::requireB", "C::requireC", "A::requireA"}, cycle)
+	})
+}
+
+func newRequireCompileContextData(t *testing.T, srcDir string) *CompileContextData {
+	t.Helper()
+	return initCompileContextData(t, getCompileContextData(
+		&model.CompilerConfig{ //nolint:exhaustruct // test
+			SrcDirs: []string{srcDir},
+			AnnotationsConfig: structures.Dictionary{
+				"checkRequirementsTemplateName": "checkRequirements",
+				"requireTemplateName":           "require",
+				"requireBootstrapTemplateName":  "requireBootstrap",
+			},
+		},
+		func(templateContextData *render.TemplateContextData, templateName string) (string, error) {
+			data, ok := templateContextData.Data.(map[string]any)
+			if !ok {
+				return "", validationError("invalid Data", templateContextData.Data)
+			}
+			if requirements, ok := data[templateFieldRequirements].([]Requirement); ok && templateName == "requireBootstrap" {
+				return fmt.Sprintf("# bootstrap %v\n", getRequiredFunctionNames(requirements)), nil
+			}
+			return fmt.Sprintf("%v", data[templateFieldCode]), nil
+		},
+	), NewRequireAnnotationProcessor())
+}
+
+func TestRequireCompile(t *testing.T) {
+	srcDir := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(srcDir, "My"), 0o755))
+	writeFunction := func(functionName string, content string) {
+		assert.NilError(t, os.WriteFile(filepath.Join(srcDir, "My", functionName+".sh"), []byte(content), 0o600))
+	}
+	writeFunction("func", "#!/bin/bash\n# @require My::requireLoad\nMy::func() { :; }\n")
+	writeFunction("requireLoad", "#!/bin/bash\n# @require My::requireRootDir\nMy::requireLoad() { :; }\n")
+	writeFunction("requireRootDir", "#!/bin/bash\nMy::requireRootDir() { :; }\n")
+	writeFunction("unknownRequire", "#!/bin/bash\n# @require My::unknown\nMy::unknownRequire() { :; }\n")
+	writeFunction("cycle", "#!/bin/bash\n# @require My::requireCycle\nMy::cycle() { :; }\n")
+	writeFunction("requireCycle", "#!/bin/bash\n# @require My::cycle\nMy::requireCycle() { :; }\n")
+
+	t.Run("required functions included", func(t *testing.T) {
+		compileContextData := newRequireCompileContextData(t, srcDir)
+		code, err := compileContextData.compileContext.Compile(
+			compileContextData, "# FUNCTIONS\nmain() {\n  # @requireBootstrap\n  My::func\n}\n",
+		)
+		assert.NilError(t, err)
+		assert.Assert(t, strings.Contains(code, "My::requireLoad() { :; }"))
+		assert.Assert(t, strings.Contains(code, "My::requireRootDir() { :; }"))
+		// requirements called in place of the placeholder in dependency order
+		assert.Assert(t, strings.Contains(code, "main() {\n  # bootstrap [My::requireRootDir My::requireLoad]\n  My::func\n"))
+	})
+	t.Run("required function called by the binary code", func(t *testing.T) {
+		compileContextData := newRequireCompileContextData(t, srcDir)
+		code, err := compileContextData.compileContext.Compile(
+			compileContextData, "# FUNCTIONS\nmain() {\n  # @requireBootstrap\n  My::requireLoad\n  My::func\n}\n",
+		)
+		assert.NilError(t, err)
+		// requirements called by the binary code are bootstrapped too, so their own requirements are loaded
+		assert.Assert(t, strings.Contains(
+			code, "main() {\n  # bootstrap [My::requireRootDir My::requireLoad]\n  My::requireLoad\n",
+		))
+	})
+	t.Run("no placeholder", func(t *testing.T) {
+		var logs bytes.Buffer
+		defaultLogger := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+		defer slog.SetDefault(defaultLogger)
+		compileContextData := newRequireCompileContextData(t, srcDir)
+		code, err := compileContextData.compileContext.Compile(compileContextData, "# FUNCTIONS\nMy::func\n")
+		assert.NilError(t, err)
+		assert.Assert(t, !strings.Contains(code, "# bootstrap"))
+		// requirements never called, the missing placeholder is reported
+		assert.Assert(t, strings.Contains(logs.String(), "Generated code not inserted, placeholder not found"))
+	})
+	t.Run("required function not found", func(t *testing.T) {
+		compileContextData := newRequireCompileContextData(t, srcDir)
+		_, err := compileContextData.compileContext.Compile(compileContextData, "# FUNCTIONS\nMy::unknownRequire\n")
+		assert.ErrorContains(t, err, "function not found: My::unknown in any srcDirs")
+	})
+	t.Run("require cycle", func(t *testing.T) {
+		compileContextData := newRequireCompileContextData(t, srcDir)
+		_, err := compileContextData.compileContext.Compile(compileContextData, "# FUNCTIONS\nMy::cycle\n")
+		assert.Error(t, err, "@require cycle detected: My::cycle -> My::requireCycle -> My::cycle")
+	})
+}
+
+func TestRequireBootstrapRun(t *testing.T) {
+	srcDir := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(srcDir, "My"), 0o755))
+	for functionName, content := range map[string]string{
+		"func":       "# @require My::requireLog\nMy::func() {\n  echo func\n}\n",
+		"requireLog": "# @require My::requireEnv\nMy::requireLog() {\n  echo requireLog\n}\n",
+		"requireEnv": "My::requireEnv() {\n  echo requireEnv\n}\n",
+	} {
+		err := os.WriteFile(
+			filepath.Join(srcDir, "My", functionName+".sh"), []byte("#!/bin/bash\n"+content), 0o600,
+		)
+		assert.NilError(t, err)
+	}
+	templateContext := render.NewTemplateContext()
+	templateContextData, err := templateContext.Init(
+		[]string{filepath.Join("..", "..", "cmd", "bash-compiler", "defaultTemplates", "annotations")},
+		"binFile.gtpl", nil, render.FuncMap(),
+	)
+	assert.NilError(t, err)
+	compileContext := NewCompiler(templateContext, []AnnotationProcessorInterface{NewRequireAnnotationProcessor()})
+	compileContextData, err := compileContext.Init(templateContextData, &model.CompilerConfig{ //nolint:exhaustruct // test
+		SrcDirs: []string{srcDir},
+		AnnotationsConfig: structures.Dictionary{
+			"checkRequirementsTemplateName": "checkRequirements",
+			"requireTemplateName":           "require",
+			"requireBootstrapTemplateName":  "requireBootstrap",
+		},
+	})
+	assert.NilError(t, err)
+
+	// the main code calls My::requireEnv explicitly, My::requireLog requiring it
+	code, err := compileContext.Compile(compileContextData, "#!/usr/bin/env bash\n# FUNCTIONS\nmain() {\n"+
+		"  echo hook\n  # @requireBootstrap\n  My::requireEnv\n  My::func\n}\nmain\n")
+	assert.NilError(t, err)
+	output, err := exec.Command("bash", "-c", code).CombinedOutput()
+	assert.NilError(t, err, string(output))
+	// requirements bootstrapped after the hook in dependency order
+	assert.Equal(t, "hook\nrequireEnv\nrequireLog\nrequireEnv\nfunc\n", string(output))
 }
 
 func TestRequireProcess(t *testing.T) {
@@ -196,6 +458,7 @@ func getRequireProcessorMocked() *requireAnnotationProcessor {
 		annotationProcessor:           annotationProcessor{},
 		checkRequirementsTemplateName: "templateName",
 		requireTemplateName:           "requireTemplateName",
+		bootstrapPlaceholder:          newTemplatePlaceholder(requireBootstrapRegexp),
 	}
 
 	return requireProcessor
@@ -204,7 +467,7 @@ func getRequireProcessorMocked() *requireAnnotationProcessor {
 func TestRequirePostProcess(t *testing.T) {
 	mock := getRequireProcessorMocked()
 	var requireProcessor AnnotationProcessorInterface = mock
-	compileContextData := getCompileContextData()
+	compileContextData := getCompileContextData(nil, nil)
 	code, err := requireProcessor.PostProcess(compileContextData, "# @require Env::requireLoad")
 	assert.Equal(t, nil, err)
 	assert.Equal(t, "# @require Env::requireLoad", code)
@@ -0,0 +1,36 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/fchastanet/bash-compiler/internal/model"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// formatBashCode reformats the code using the formatter options,
+// the code is returned unchanged if the formatter is disabled or if the code cannot be parsed
+// (the syntax error is reported by the syntax validation).
+// Comments are kept so that source map markers are preserved.
+func formatBashCode(code string, config *model.FormatterConfig) (string, error) {
+	if !config.Enabled || code == "" {
+		return code, nil
+	}
+	parser := syntax.NewParser(syntax.Variant(syntax.LangBash), syntax.KeepComments(true))
+	file, err := parser.Parse(strings.NewReader(code), "")
+	if err != nil {
+		return code, nil //nolint:nilerr // reported by validateBashSyntax
+	}
+	printer := syntax.NewPrinter(
+		syntax.Indent(config.Indent),
+		syntax.BinaryNextLine(config.BinaryNextLine),
+		syntax.SwitchCaseIndent(config.SwitchCaseIndent),
+		syntax.SpaceRedirects(config.SpaceRedirects),
+		syntax.FunctionNextLine(config.FunctionNextLine),
+	)
+	var formattedCode strings.Builder
+	err = printer.Print(&formattedCode, file)
+	if err != nil {
+		return "", err
+	}
+	return formattedCode.String(), nil
+}
@@ -0,0 +1,70 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/model"
+	"gotest.tools/v3/assert"
+)
+
+func TestFormatBashCode(t *testing.T) {
+	code := "#!/bin/bash\n# comment\nMy::func() {\n        if true;   then\n    echo ok >&2 &&\n  echo ko\n fi\n}\n"
+	tests := []struct {
+		name     string
+		config   model.FormatterConfig
+		code     string
+		expected string
+	}{
+		{
+			name:     "disabled",
+			config:   model.FormatterConfig{Enabled: false, Indent: 2}, //nolint:exhaustruct // test
+			code:     code,
+			expected: code,
+		},
+		{
+			name:   "indent with 2 spaces",
+			config: model.FormatterConfig{Enabled: true, Indent: 2}, //nolint:exhaustruct // test
+			code:   code,
+			expected: "#!/bin/bash\n# comment\nMy::func() {\n  if true; then\n    echo ok >&2 &&\n" +
+				"      echo ko\n  fi\n}\n",
+		},
+		{
+			name: "tabs, binary and function next line",
+			config: model.FormatterConfig{ //nolint:exhaustruct // test
+				Enabled: true, Indent: 0, BinaryNextLine: true, FunctionNextLine: true,
+			},
+			code: code,
+			expected: "#!/bin/bash\n# comment\nMy::func()\n{\n\tif true; then\n\t\techo ok >&2 \\\n" +
+				"\t\t\t&& echo ko\n\tfi\n}\n",
+		},
+		{
+			name:     "invalid code kept unchanged",
+			config:   model.FormatterConfig{Enabled: true, Indent: 2}, //nolint:exhaustruct // test
+			code:     "if true; then\n",
+			expected: "if true; then\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formattedCode, err := formatBashCode(tt.code, &tt.config)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.expected, formattedCode)
+		})
+	}
+}
+
+func TestFormatBashCodeKeepsSourceMapMarkers(t *testing.T) {
+	code := "main() {\n" +
+		"# @sourceMap begin includedFile 1 - src/main.sh\n" +
+		"    echo main\n" +
+		"# @sourceMap end\n" +
+		"}\n"
+	config := model.FormatterConfig{Enabled: true, Indent: 2} //nolint:exhaustruct // test
+	formattedCode, err := formatBashCode(code, &config)
+	assert.NilError(t, err)
+	newCode, sourceMap := extractSourceMap(formattedCode, "binFile.gtpl", false)
+	assert.Equal(t, "main() {\n  echo main\n}\n", newCode)
+	location, err := sourceMap.Locate(2)
+	assert.NilError(t, err)
+	assert.Equal(t, "src/main.sh:1 (includedFile)", location.String())
+}
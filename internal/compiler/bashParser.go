@@ -0,0 +1,211 @@
+package compiler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// commandsRunningTheirArgument run the command given as first non option argument
+var commandsRunningTheirArgument = map[string]bool{
+	"builtin": true,
+	"command": true,
+	"exec":    true,
+	"nohup":   true,
+	"time":    true,
+	"xargs":   true,
+}
+
+type invalidBashSyntaxError struct {
+	error
+	// Line is the line of the compiled code where the error has been detected
+	Line     int
+	Message  string
+	Location *SourceLocation
+}
+
+func (e *invalidBashSyntaxError) Error() string {
+	msg := fmt.Sprintf("compiled code is not valid bash at line %d: %s", e.Line, e.Message)
+	if e.Location != nil {
+		msg = fmt.Sprintf("%s - generated from %s", msg, e.Location.String())
+	}
+	return msg
+}
+
+// parseBashCode parses the code using bash syntax
+func parseBashCode(code string) (*syntax.File, error) {
+	parser := syntax.NewParser(syntax.Variant(syntax.LangBash))
+	return parser.Parse(strings.NewReader(code), "")
+}
+
+// extractFrameworkFunctionReferences returns the sorted unique framework
+// functions invoked, defined or passed unquoted as argument (eg: callback) in the code.
+// Strings, heredocs, comments and variable values are not taken into account,
+// the regexp based detection is used if the code cannot be parsed.
+func extractFrameworkFunctionReferences(code string) []string {
+	references := map[string]bool{}
+	file, err := parseBashCode(code)
+	if err != nil {
+		slog.Debug("Code cannot be parsed, falling back to regexp detection", logger.LogFieldErr, err)
+		extractFrameworkFunctionReferencesUsingRegexp(code, references)
+	} else {
+		extractFrameworkFunctionReferencesFromNode(file, references)
+	}
+	functionNames := make([]string, 0, len(references))
+	for functionName := range references {
+		functionNames = append(functionNames, functionName)
+	}
+	sort.Strings(functionNames)
+	return functionNames
+}
+
+func extractFrameworkFunctionReferencesFromNode(node syntax.Node, references map[string]bool) {
+	syntax.Walk(node, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.FuncDecl:
+			addFrameworkFunctionReference(node.Name.Value, references)
+		case *syntax.CallExpr:
+			extractFrameworkFunctionReferencesFromCall(node, references)
+		}
+		return true
+	})
+}
+
+func extractFrameworkFunctionReferencesFromCall(call *syntax.CallExpr, references map[string]bool) {
+	if len(call.Args) == 0 {
+		return
+	}
+	commandName := getWordLiteral(call.Args[0])
+	addFrameworkFunctionReference(commandName, references)
+	for _, arg := range call.Args[1:] {
+		// unquoted framework function passed as callback (eg: Array::map arr Foo::bar)
+		if len(arg.Parts) == 1 {
+			if lit, ok := arg.Parts[0].(*syntax.Lit); ok {
+				addFrameworkFunctionReference(lit.Value, references)
+			}
+		}
+	}
+	if len(call.Args) < 2 {
+		return
+	}
+	switch {
+	case commandName == "trap":
+		// the first argument of trap is the code to execute
+		handlerCode := getWordLiteral(call.Args[1])
+		file, err := parseBashCode(handlerCode)
+		if err == nil {
+			extractFrameworkFunctionReferencesFromNode(file, references)
+		}
+	case commandsRunningTheirArgument[commandName]:
+		for _, arg := range call.Args[1:] {
+			argValue := getWordLiteral(arg)
+			if !strings.HasPrefix(argValue, "-") {
+				addFrameworkFunctionReference(argValue, references)
+				return
+			}
+		}
+	}
+}
+
+func addFrameworkFunctionReference(functionName string, references map[string]bool) {
+	if IsBashFrameworkFunction([]byte(functionName)) {
+		references[functionName] = true
+	}
+}
+
+// getWordLiteral returns the value of the word if it does not contain
+// any expansion, empty string otherwise
+func getWordLiteral(word *syntax.Word) string {
+	var literal strings.Builder
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			literal.WriteString(part.Value)
+		case *syntax.SglQuoted:
+			literal.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			for _, quotedPart := range part.Parts {
+				lit, ok := quotedPart.(*syntax.Lit)
+				if !ok {
+					return ""
+				}
+				literal.WriteString(lit.Value)
+			}
+		default:
+			return ""
+		}
+	}
+	return literal.String()
+}
+
+func extractFrameworkFunctionReferencesUsingRegexp(code string, references map[string]bool) {
+	scanner := bufio.NewScanner(strings.NewReader(code))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if IsCommentLine(line) {
+			continue
+		}
+		for _, functionName := range bashFrameworkFunctionRegexp.FindAllString(string(line), -1) {
+			references[functionName] = true
+		}
+	}
+}
+
+// getDefinedFunctionNames returns the names of the functions defined in the code,
+// the regexp based detection is used if the code cannot be parsed
+func getDefinedFunctionNames(code string) []string {
+	file, err := parseBashCode(code)
+	if err != nil {
+		slog.Debug("Code cannot be parsed, falling back to regexp detection", logger.LogFieldErr, err)
+		functionNames := []string{}
+		bashFrameworkFunctionGroupIndex := requiredFunctionRegex.SubexpIndex("bashFrameworkFunction")
+		for _, match := range requiredFunctionRegex.FindAllStringSubmatch(code, -1) {
+			functionNames = append(functionNames, match[bashFrameworkFunctionGroupIndex])
+		}
+		return functionNames
+	}
+	functionNames := []string{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if funcDecl, ok := node.(*syntax.FuncDecl); ok {
+			functionNames = append(functionNames, funcDecl.Name.Value)
+		}
+		return true
+	})
+	return functionNames
+}
+
+// validateBashSyntax checks that the compiled code is valid bash,
+// the source map allows to indicate where the faulty line comes from
+func validateBashSyntax(code string, sourceMap *SourceMap) error {
+	_, err := parseBashCode(code)
+	if err == nil {
+		return nil
+	}
+	var pos syntax.Pos
+	var parseError syntax.ParseError
+	var langError syntax.LangError
+	switch {
+	case errors.As(err, &parseError):
+		pos = parseError.Pos
+	case errors.As(err, &langError):
+		pos = langError.Pos
+	default:
+		return err
+	}
+	syntaxError := &invalidBashSyntaxError{
+		error:    err,
+		Line:     int(pos.Line()),
+		Message:  strings.TrimPrefix(err.Error(), pos.String()+": "),
+		Location: nil,
+	}
+	if sourceMap != nil {
+		syntaxError.Location, _ = sourceMap.Locate(syntaxError.Line)
+	}
+	return syntaxError
+}
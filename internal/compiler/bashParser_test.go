@@ -0,0 +1,93 @@
+package compiler
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestExtractFrameworkFunctionReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected []string
+	}{
+		{
+			name:     "command invocations",
+			code:     "Log::info \"msg\"\nif Array::contains a b; then\n  x=\"$(Env::get HOME)\"\nfi\n",
+			expected: []string{"Array::contains", "Env::get", "Log::info"},
+		},
+		{
+			name:     "function definitions",
+			code:     "function My::first { :; }\nMy::second() {\n  :\n}\n",
+			expected: []string{"My::first", "My::second"},
+		},
+		{
+			name: "strings, comments and variables are ignored",
+			code: "# Log::comment\necho \"Use Foo::bar instead\"\nurl=http://Host::port\n" +
+				"cat <<EOF\nHeredoc::function\nEOF\nprintf '%s' 'Single::quoted'\n",
+			expected: []string{},
+		},
+		{
+			name:     "commands running their argument",
+			code:     "command -v Log::info\nxargs -0 Log::warning\ntrap 'Env::cleanup; exit 1' EXIT\n",
+			expected: []string{"Env::cleanup", "Log::info", "Log::warning"},
+		},
+		{
+			name:     "functions passed as callback",
+			code:     "Array::map arr Foo::bar\nArray::filter -n 'Quoted::callback' arr\nLog::info Log::displayInfo\n",
+			expected: []string{"Array::filter", "Array::map", "Foo::bar", "Log::displayInfo", "Log::info"},
+		},
+		{
+			name:     "fallback to regexp when code cannot be parsed",
+			code:     "Log::info \"unterminated\n# Log::comment\n",
+			expected: []string{"Log::info"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.DeepEqual(t, tt.expected, extractFrameworkFunctionReferences(tt.code))
+		})
+	}
+}
+
+func TestIsCodeContainsFunction(t *testing.T) {
+	t.Run("function keyword without parenthesis", func(t *testing.T) {
+		assert.NilError(t, isCodeContainsFunction("function My::func\n{\n  :\n}\n", "My::func"))
+	})
+	t.Run("function name only in a string", func(t *testing.T) {
+		err := isCodeContainsFunction("echo 'My::func() {'\n", "My::func")
+		assert.Error(t, err, "required function not found in parsed code: My::func")
+	})
+	t.Run("other function defined", func(t *testing.T) {
+		err := isCodeContainsFunction("My::other() { :; }\n", "My::func")
+		assert.ErrorType(t, err, &requiredFunctionNotFoundError{})
+	})
+}
+
+func TestValidateBashSyntax(t *testing.T) {
+	sourceMap := &SourceMap{
+		File:      "",
+		LineCount: 3,
+		Mappings: []SourceMapping{
+			{StartLine: 1, EndLine: 1, Kind: SourceMappingKindTemplate, SrcFile: "binFile.gtpl"},
+			{
+				StartLine: 2, EndLine: 3, Kind: SourceMappingKindFunction,
+				FunctionName: "My::func", SrcFile: "src/My/func.sh", SrcLine: 3,
+			},
+		},
+	}
+	t.Run("valid code", func(t *testing.T) {
+		assert.NilError(t, validateBashSyntax("#!/bin/bash\nMy::func() {\n  :\n}\n", sourceMap))
+	})
+	t.Run("error located in function", func(t *testing.T) {
+		err := validateBashSyntax("#!/bin/bash\nMy::func() {\n  echo )\n}\n", sourceMap)
+		assert.Error(t, err, "compiled code is not valid bash at line 3: a command can only contain words and redirects;"+
+			" encountered `)` - generated from src/My/func.sh:4 (function My::func)")
+	})
+	t.Run("error without source map", func(t *testing.T) {
+		err := validateBashSyntax("if true; then\n", nil)
+		assert.ErrorType(t, err, &invalidBashSyntaxError{})
+		assert.ErrorContains(t, err, "compiled code is not valid bash at line 1: ")
+	})
+}
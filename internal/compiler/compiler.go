@@ -136,6 +136,20 @@ func (context CompileContextData) Validate() error {
 	return nil
 }
 
+// GetFunctionsSrcFiles returns the sorted source files of the functions
+// that have been resolved during compilation
+func (context *CompileContextData) GetFunctionsSrcFiles() []string {
+	srcFiles := make([]string, 0, len(context.functionsMap))
+	for _, functionName := range getSortedFunctionNamesFromMap(context.functionsMap) {
+		functionInfo := context.functionsMap[functionName]
+		if functionInfo.SrcFile != "" {
+			srcFiles = append(srcFiles, functionInfo.SrcFile)
+		}
+	}
+	sort.Strings(srcFiles)
+	return srcFiles
+}
+
 // Compile generates code from given model
 func NewCompiler(
 	templateContext render.TemplateContextInterface,
@@ -171,16 +185,27 @@ func (context CompileContext) Init(
 func (context CompileContext) Compile(
 	compileContextData *CompileContextData, code string,
 ) (codeCompiled string, err error) {
+	codeCompiled, _, err = context.CompileWithSourceMap(compileContextData, code)
+	return codeCompiled, err
+}
+
+// CompileWithSourceMap compiles the code and computes the source map linking
+// each line of the compiled code to the function file, included file or template it comes from,
+// the source map is nil if compilerConfig.sourceMap and compilerConfig.sourceMapMarkers are disabled
+func (context CompileContext) CompileWithSourceMap(
+	compileContextData *CompileContextData, code string,
+) (codeCompiled string, sourceMap *SourceMap, err error) {
 	_, err = context.computeFunctions(compileContextData, code)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
+	context.checkDependencyGraph(compileContextData, code)
 	compileContextData.config.DebugSaveIntermediateFile(code, "-compiler::Compile1")
 
 	context.markAllFunctionsAsNotInserted(compileContextData)
 	_, generatedCode, err := context.generateCode(compileContextData, code)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	compileContextData.config.DebugSaveIntermediateFile(generatedCode, "-compiler::Compile2")
 
@@ -188,12 +213,37 @@ func (context CompileContext) Compile(
 		annotationProcessor.Reset()
 		generatedCode, err := annotationProcessor.PostProcess(compileContextData, generatedCode)
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
 		compileContextData.config.DebugSaveIntermediateFile(generatedCode, "-after-"+annotationProcessor.GetTitle())
 	}
 
-	return context.formatCode(generatedCode), nil
+	generatedCode, err = formatBashCode(generatedCode, &compileContextData.config.Formatter)
+	if err != nil {
+		return "", nil, err
+	}
+	compileContextData.config.DebugSaveIntermediateFile(generatedCode, "-compiler::format")
+
+	if compileContextData.config.IsSourceMapEnabled() {
+		generatedCode, sourceMap = extractSourceMap(
+			generatedCode,
+			os.ExpandEnv(compileContextData.config.TemplateFile),
+			compileContextData.config.SourceMapMarkers,
+		)
+	}
+	codeCompiled = context.formatCode(generatedCode)
+	err = validateBashSyntax(codeCompiled, sourceMap)
+	if err != nil {
+		if compileContextData.config.SyntaxValidation != model.SyntaxValidationWarning {
+			return "", nil, err
+		}
+		slog.Warn(
+			"Compiled code is not valid bash",
+			logger.LogFieldFilePath, os.ExpandEnv(compileContextData.config.TargetFile),
+			logger.LogFieldErr, err,
+		)
+	}
+	return codeCompiled, sourceMap, nil
 }
 
 func (context CompileContext) computeFunctions(
@@ -281,21 +331,25 @@ func (context CompileContext) functionsAnalysis(
 	code string,
 ) (err error) {
 	context.extractUniqueFrameworkFunctions(compileContextData, code)
-	_, err = context.retrieveEachFunctionPath(compileContextData)
-	if err != nil {
-		return err
-	}
-	newFunctionAdded := true
-	for newFunctionAdded {
-		newFunctionAdded, err = context.retrieveAllFunctionsContent(compileContextData)
+	// annotation processors can add functions while parsing the functions (eg: @require),
+	// these functions are loaded and rendered like the ones referenced in the code
+	for newFunctionToLoad := true; newFunctionToLoad; newFunctionToLoad = hasFunctionToLoad(compileContextData) {
+		_, err = context.retrieveEachFunctionPath(compileContextData)
 		if err != nil {
 			return err
 		}
-	}
+		newFunctionAdded := true
+		for newFunctionAdded {
+			newFunctionAdded, err = context.retrieveAllFunctionsContent(compileContextData)
+			if err != nil {
+				return err
+			}
+		}
 
-	err = context.renderEachFunctionAsTemplate(compileContextData)
-	if err != nil {
-		return err
+		err = context.renderEachFunctionAsTemplate(compileContextData)
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, annotationProcessor := range context.annotationProcessors {
@@ -396,20 +450,16 @@ func (context CompileContext) generateFunctionCode(
 	code string,
 	err error,
 ) {
-	functionNames := getSortedFunctionNamesFromMap(compileContextData.functionsMap)
-
 	var finalBuffer bytes.Buffer
-	err = context.insertFunctionsCode(compileContextData, functionNames, &finalBuffer, InsertPositionFirst)
-	if err != nil {
-		return "", err
-	}
-	err = context.insertFunctionsCode(compileContextData, functionNames, &finalBuffer, InsertPositionMiddle)
-	if err != nil {
-		return "", err
-	}
-	err = context.insertFunctionsCode(compileContextData, functionNames, &finalBuffer, InsertPositionLast)
-	if err != nil {
-		return "", err
+	for _, insertPosition := range []InsertPosition{InsertPositionFirst, InsertPositionMiddle, InsertPositionLast} {
+		functionNames, err := sortFunctionsToInsert(compileContextData.functionsMap, insertPosition)
+		if err != nil {
+			return "", err
+		}
+		err = context.insertFunctionsCode(compileContextData, functionNames, &finalBuffer, insertPosition)
+		if err != nil {
+			return "", err
+		}
 	}
 	slog.Debug("Final Buffer length", LogFieldLength, finalBuffer.Len())
 	return finalBuffer.String(), nil
@@ -447,7 +497,13 @@ func (CompileContext) insertFunctionsCode(
 			LogFieldSourceCodeLen, len(functionInfo.SourceCode),
 			LogFieldInsertPosition, functionInfo.InsertPosition,
 		)
-		_, err := buffer.WriteString(functionInfo.SourceCode)
+		sourceCode := functionInfo.SourceCode
+		if compileContextData.config.IsSourceMapEnabled() {
+			sourceCode = render.AddSourceMapMarkers(
+				sourceCode, render.SourceMapKindFunction, functionName, functionInfo.SrcFile,
+			)
+		}
+		_, err := buffer.WriteString(sourceCode)
 		if err != nil {
 			return err
 		}
@@ -535,6 +591,15 @@ func (context CompileContext) addSpecialFiles(
 	return true
 }
 
+func hasFunctionToLoad(compileContextData *CompileContextData) bool {
+	for _, functionInfo := range compileContextData.functionsMap {
+		if !functionInfo.SourceCodeLoaded {
+			return true
+		}
+	}
+	return false
+}
+
 func getSortedFunctionNamesFromMap(myMap map[string]functionInfoStruct) []string {
 	functionNames := structures.MapKeys(myMap)
 	sort.Strings(functionNames)
@@ -590,31 +655,21 @@ func (context CompileContext) extractUniqueFrameworkFunctions(
 	if code == "" {
 		return false
 	}
-	var rewrittenCode bytes.Buffer
 	newFunctionAdded = false
-	scanner := bufio.NewScanner(strings.NewReader(code))
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		rewrittenCode.Write(line)
-		if IsCommentLine(line) {
-			continue
-		}
-		matches := bashFrameworkFunctionRegexp.FindAllString(string(line), -1)
-		for _, funcName := range matches {
-			if _, keyExists := compileContextData.functionsMap[funcName]; !keyExists {
-				slog.Debug("Found new",
-					logger.LogFieldVariableName, "bashFrameworkFunction",
-					logger.LogFieldVariableValue, funcName,
-				)
-				if context.isNonFrameworkFunction(compileContextData, funcName) {
-					continue
-				}
-
-				compileContextData.functionsMap[funcName] = createFunctionInfoStruct(
-					funcName, "", InsertPositionMiddle,
-				)
-				newFunctionAdded = true
+	for _, funcName := range extractFrameworkFunctionReferences(code) {
+		if _, keyExists := compileContextData.functionsMap[funcName]; !keyExists {
+			slog.Debug("Found new",
+				logger.LogFieldVariableName, "bashFrameworkFunction",
+				logger.LogFieldVariableValue, funcName,
+			)
+			if context.isNonFrameworkFunction(compileContextData, funcName) {
+				continue
 			}
+
+			compileContextData.functionsMap[funcName] = createFunctionInfoStruct(
+				funcName, "", InsertPositionMiddle,
+			)
+			newFunctionAdded = true
 		}
 	}
 
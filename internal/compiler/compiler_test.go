@@ -1,6 +1,7 @@
 package compiler
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/fchastanet/bash-compiler/internal/model"
@@ -172,3 +173,67 @@ func TestCompileDependentFunction(t *testing.T) {
 	assert.Equal(t, err, nil)
 	golden.Assert(t, resultCode, "expectedTestCompileDependentFunction.txt")
 }
+
+func TestCompileFunctionPassedAsCallback(t *testing.T) {
+	resultCode, err := compile(
+		"# FUNCTIONS\nmapfile -t -c 1 -C MyPackage::function lines < file\n",
+		[]string{},
+		simulateGoodRenderingCallback,
+		[]string{"./testdata"},
+	)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(resultCode, "MyPackage::function() {"))
+}
+
+func TestCompileInvalidBashSyntax(t *testing.T) {
+	resultCode, err := compile(
+		"# FUNCTIONS\nMyPackage::function\nif true; then\n  echo '{{ .missing }}'\n",
+		[]string{},
+		simulateGoodRenderingCallback,
+		[]string{"./testdata"},
+	)
+	assert.ErrorType(t, err, &invalidBashSyntaxError{})
+	assert.ErrorContains(t, err, "compiled code is not valid bash at line")
+	assert.Equal(t, "", resultCode)
+}
+
+func TestCompileInvalidBashSyntaxWarning(t *testing.T) {
+	compilerContextData := newMockedCompiler(simulateGoodRenderingCallback)
+	compilerContextData.config.SrcDirs = []string{"./testdata"}
+	compilerContextData.config.SyntaxValidation = model.SyntaxValidationWarning
+	resultCode, err := compilerContextData.compileContext.Compile(
+		compilerContextData,
+		"# FUNCTIONS\nMyPackage::function\nif true; then\n  echo '{{ .missing }}'\n",
+	)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasSuffix(resultCode, "if true; then\n  echo '{{ .missing }}'\n"))
+}
+
+func TestCompileWithSourceMap(t *testing.T) {
+	t.Run("source map disabled", func(t *testing.T) {
+		compilerContextData := newMockedCompiler(simulateGoodRenderingCallback)
+		compilerContextData.config.SrcDirs = []string{"./testdata"}
+		resultCode, sourceMap, err := compilerContextData.compileContext.CompileWithSourceMap(
+			compilerContextData,
+			"# FUNCTIONS\nMyPackage::function\n",
+		)
+		assert.NilError(t, err)
+		golden.Assert(t, resultCode, "expectedTestCompileOneFunctionFound.txt")
+		assert.Assert(t, sourceMap == nil)
+	})
+	t.Run("source map enabled", func(t *testing.T) {
+		compilerContextData := newMockedCompiler(simulateGoodRenderingCallback)
+		compilerContextData.config.SrcDirs = []string{"./testdata"}
+		compilerContextData.config.SourceMap = true
+		resultCode, sourceMap, err := compilerContextData.compileContext.CompileWithSourceMap(
+			compilerContextData,
+			"# FUNCTIONS\nMyPackage::function\n",
+		)
+		assert.NilError(t, err)
+		assert.Assert(t, !strings.Contains(resultCode, "@sourceMap"))
+		assert.Assert(t, sourceMap != nil)
+		location, err := sourceMap.Locate(3)
+		assert.NilError(t, err)
+		assert.Equal(t, "MyPackage::function", location.FunctionName)
+	})
+}
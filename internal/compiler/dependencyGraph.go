@@ -0,0 +1,313 @@
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+type DependencyNodeKind string
+
+const (
+	DependencyNodeKindBinary      DependencyNodeKind = "binary"
+	DependencyNodeKindFunction    DependencyNodeKind = "function"
+	DependencyNodeKindSpecialFile DependencyNodeKind = "specialFile"
+)
+
+type DependencyEdgeKind string
+
+const (
+	// DependencyEdgeKindCall the function is referenced in the code
+	DependencyEdgeKindCall DependencyEdgeKind = "call"
+	// DependencyEdgeKindRequire the function is referenced using @require annotation
+	DependencyEdgeKindRequire DependencyEdgeKind = "require"
+	// DependencyEdgeKindSpecialFile _.sh or ZZZ.sh file loaded with the function
+	DependencyEdgeKindSpecialFile DependencyEdgeKind = "specialFile"
+	// DependencyEdgeKindGenerated the function is referenced by generated code
+	// (eg: code added by an annotation processor)
+	DependencyEdgeKindGenerated DependencyEdgeKind = "generated"
+)
+
+type unknownDependencyGraphFormatError struct {
+	error
+	Format string
+}
+
+func (e *unknownDependencyGraphFormatError) Error() string {
+	return "unknown dependency graph format: " + e.Format
+}
+
+type functionNotIncludedError struct {
+	error
+	FunctionName string
+	Binary       string
+}
+
+func (e *functionNotIncludedError) Error() string {
+	return fmt.Sprintf("function %s is not included in binary %s", e.FunctionName, e.Binary)
+}
+
+type DependencyNode struct {
+	ID      string             `json:"id"`
+	Label   string             `json:"label"`
+	Kind    DependencyNodeKind `json:"kind"`
+	SrcFile string             `json:"srcFile,omitempty"`
+}
+
+type DependencyEdge struct {
+	From string             `json:"from"`
+	To   string             `json:"to"`
+	Kind DependencyEdgeKind `json:"kind"`
+}
+
+// DependencyGraph describes why each function has been included in a binary
+type DependencyGraph struct {
+	Binary              string           `json:"binary"`
+	BinaryModelFilePath string           `json:"binaryModelFilePath"`
+	Nodes               []DependencyNode `json:"nodes"`
+	Edges               []DependencyEdge `json:"edges"`
+}
+
+// Analyze runs the functions analysis on the code
+// and returns the dependency graph of the functions included
+func (context CompileContext) Analyze(
+	compileContextData *CompileContextData,
+	code string,
+	binary string,
+) (*DependencyGraph, error) {
+	_, err := context.computeFunctions(compileContextData, code)
+	if err != nil {
+		return nil, err
+	}
+	return context.newDependencyGraph(compileContextData, code, binary), nil
+}
+
+func (context CompileContext) newDependencyGraph(
+	compileContextData *CompileContextData,
+	code string,
+	binary string,
+) *DependencyGraph {
+	graph := &DependencyGraph{
+		Binary:              binary,
+		BinaryModelFilePath: compileContextData.config.BinaryModelFilePath,
+		Nodes:               []DependencyNode{{ID: binary, Label: binary, Kind: DependencyNodeKindBinary, SrcFile: ""}},
+		Edges:               []DependencyEdge{},
+	}
+	functionsMap := compileContextData.functionsMap
+	functionNames := getSortedFunctionNamesFromMap(functionsMap)
+	for _, functionName := range functionNames {
+		functionInfo := functionsMap[functionName]
+		node := DependencyNode{
+			ID:      functionName,
+			Label:   functionName,
+			Kind:    DependencyNodeKindFunction,
+			SrcFile: functionInfo.SrcFile,
+		}
+		if functionInfo.InsertPosition != InsertPositionMiddle {
+			node.Kind = DependencyNodeKindSpecialFile
+			node.Label = specialFileLabel(compileContextData, functionName)
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	graph.addCallEdges(binary, code, functionsMap)
+	for _, functionName := range functionNames {
+		functionInfo := functionsMap[functionName]
+		if functionInfo.InsertPosition != InsertPositionMiddle {
+			continue
+		}
+		graph.addCallEdges(functionName, functionInfo.SourceCode, functionsMap)
+		requireAnnotation, err := functionInfo.getRequireAnnotation()
+		if err == nil {
+			for _, requiredFunction := range requireAnnotation.requiredFunctions {
+				graph.addEdge(functionName, requiredFunction, DependencyEdgeKindRequire)
+			}
+		}
+		relativeFilePathDir := filepath.Dir(convertFunctionNameToPath(functionName))
+		for _, specialFilename := range []string{"_.sh", "ZZZ.sh"} {
+			specialFile, found := context.findFileInSrcDirs(
+				compileContextData, filepath.Join(relativeFilePathDir, specialFilename),
+			)
+			if _, included := functionsMap[specialFile]; found && included {
+				graph.addEdge(functionName, specialFile, DependencyEdgeKindSpecialFile)
+			}
+		}
+	}
+
+	// functions not reachable have been added by generated code
+	reachableNodes := graph.getReachableNodes()
+	for _, functionName := range functionNames {
+		if !reachableNodes[functionName] {
+			graph.addEdge(binary, functionName, DependencyEdgeKindGenerated)
+		}
+	}
+	return graph
+}
+
+func specialFileLabel(compileContextData *CompileContextData, specialFile string) string {
+	for _, srcDir := range compileContextData.config.SrcDirsExpanded {
+		relativePath, err := filepath.Rel(srcDir, specialFile)
+		if err == nil && !strings.HasPrefix(relativePath, "..") {
+			return relativePath
+		}
+	}
+	return filepath.Base(filepath.Dir(specialFile)) + "/" + filepath.Base(specialFile)
+}
+
+func (graph *DependencyGraph) addCallEdges(
+	from string, code string, functionsMap map[string]functionInfoStruct,
+) {
+	for _, functionName := range extractFrameworkFunctionReferences(code) {
+		if _, included := functionsMap[functionName]; included && functionName != from {
+			graph.addEdge(from, functionName, DependencyEdgeKindCall)
+		}
+	}
+}
+
+func (graph *DependencyGraph) addEdge(from string, to string, kind DependencyEdgeKind) {
+	for _, edge := range graph.Edges {
+		if edge.From == from && edge.To == to {
+			return
+		}
+	}
+	graph.Edges = append(graph.Edges, DependencyEdge{From: from, To: to, Kind: kind})
+}
+
+func (graph *DependencyGraph) getReachableNodes() map[string]bool {
+	reachableNodes := map[string]bool{}
+	for _, chain := range graph.getShortestChains() {
+		for _, edge := range chain {
+			reachableNodes[edge.To] = true
+		}
+	}
+	return reachableNodes
+}
+
+// getShortestChains returns for each node reachable from the binary node
+// the shortest list of edges leading to it (breadth first search)
+func (graph *DependencyGraph) getShortestChains() map[string][]DependencyEdge {
+	chains := map[string][]DependencyEdge{graph.Binary: {}}
+	queue := []string{graph.Binary}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edge := range graph.Edges {
+			if edge.From != current {
+				continue
+			}
+			if _, visited := chains[edge.To]; visited {
+				continue
+			}
+			chain := make([]DependencyEdge, len(chains[current]), len(chains[current])+1)
+			copy(chain, chains[current])
+			chains[edge.To] = append(chain, edge)
+			queue = append(queue, edge.To)
+		}
+	}
+	return chains
+}
+
+// ReferenceChain returns the shortest chain of references explaining
+// why the function is included in the binary
+func (graph *DependencyGraph) ReferenceChain(functionName string) ([]DependencyEdge, error) {
+	chain, found := graph.getShortestChains()[functionName]
+	if !found || functionName == graph.Binary {
+		return nil, &functionNotIncludedError{nil, functionName, graph.Binary}
+	}
+	return chain, nil
+}
+
+func (graph *DependencyGraph) getNodeLabel(id string) string {
+	for _, node := range graph.Nodes {
+		if node.ID == id {
+			return node.Label
+		}
+	}
+	return id
+}
+
+// WriteDependencyGraphs writes the graphs using the given format (dot, mermaid or json)
+func WriteDependencyGraphs(output io.Writer, format string, graphs []*DependencyGraph) error {
+	switch format {
+	case "dot":
+		return writeDot(output, graphs)
+	case "mermaid":
+		return writeMermaid(output, graphs)
+	case "json":
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(graphs)
+	}
+	return &unknownDependencyGraphFormatError{nil, format}
+}
+
+func writeDot(output io.Writer, graphs []*DependencyGraph) error {
+	var buffer strings.Builder
+	buffer.WriteString("digraph dependencies {\n  rankdir=LR;\n")
+	for index, graph := range graphs {
+		fmt.Fprintf(&buffer, "  subgraph cluster_%d {\n    label=%q;\n", index, graph.Binary)
+		for _, node := range graph.Nodes {
+			shape := "box"
+			switch node.Kind {
+			case DependencyNodeKindBinary:
+				shape = "doubleoctagon"
+			case DependencyNodeKindSpecialFile:
+				shape = "note"
+			case DependencyNodeKindFunction:
+			}
+			fmt.Fprintf(
+				&buffer, "    %q [label=%q, shape=%s];\n",
+				fmt.Sprintf("%d:%s", index, node.ID), node.Label, shape,
+			)
+		}
+		for _, edge := range graph.Edges {
+			fmt.Fprintf(
+				&buffer, "    %q -> %q [label=%q];\n",
+				fmt.Sprintf("%d:%s", index, edge.From), fmt.Sprintf("%d:%s", index, edge.To), edge.Kind,
+			)
+		}
+		buffer.WriteString("  }\n")
+	}
+	buffer.WriteString("}\n")
+	_, err := io.WriteString(output, buffer.String())
+	return err
+}
+
+func writeMermaid(output io.Writer, graphs []*DependencyGraph) error {
+	var buffer strings.Builder
+	buffer.WriteString("flowchart LR\n")
+	for index, graph := range graphs {
+		nodeIDs := map[string]string{}
+		fmt.Fprintf(&buffer, "  subgraph binary%d [%q]\n", index, graph.Binary)
+		for nodeIndex, node := range graph.Nodes {
+			nodeID := fmt.Sprintf("b%dn%d", index, nodeIndex)
+			nodeIDs[node.ID] = nodeID
+			switch node.Kind {
+			case DependencyNodeKindBinary:
+				fmt.Fprintf(&buffer, "    %s{{%q}}\n", nodeID, node.Label)
+			case DependencyNodeKindSpecialFile:
+				fmt.Fprintf(&buffer, "    %s[/%q/]\n", nodeID, node.Label)
+			case DependencyNodeKindFunction:
+				fmt.Fprintf(&buffer, "    %s[%q]\n", nodeID, node.Label)
+			}
+		}
+		for _, edge := range graph.Edges {
+			fmt.Fprintf(&buffer, "    %s -->|%s| %s\n", nodeIDs[edge.From], edge.Kind, nodeIDs[edge.To])
+		}
+		buffer.WriteString("  end\n")
+	}
+	_, err := io.WriteString(output, buffer.String())
+	return err
+}
+
+// FormatReferenceChain formats the reference chain on one line
+func (graph *DependencyGraph) FormatReferenceChain(chain []DependencyEdge) string {
+	var buffer strings.Builder
+	buffer.WriteString(graph.Binary)
+	for _, edge := range chain {
+		fmt.Fprintf(&buffer, " -[%s]-> %s", edge.Kind, graph.getNodeLabel(edge.To))
+	}
+	return buffer.String()
+}
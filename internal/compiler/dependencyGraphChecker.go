@@ -0,0 +1,30 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dependencyGraphCheckerInterface is implemented by the annotation processors
+// needing the dependency graph of the functions once they are all known
+type dependencyGraphCheckerInterface interface {
+	isDependencyGraphNeeded(compileContextData *CompileContextData) bool
+	checkDependencyGraph(compileContextData *CompileContextData, graph *DependencyGraph)
+}
+
+// checkDependencyGraph provides the dependency graph of the functions to the
+// annotation processors needing it, the graph is computed only if needed
+func (context CompileContext) checkDependencyGraph(compileContextData *CompileContextData, code string) {
+	var graph *DependencyGraph
+	for _, annotationProcessor := range context.annotationProcessors {
+		checker, ok := annotationProcessor.(dependencyGraphCheckerInterface)
+		if !ok || !checker.isDependencyGraphNeeded(compileContextData) {
+			continue
+		}
+		if graph == nil {
+			binary := filepath.Base(os.ExpandEnv(compileContextData.config.TargetFile))
+			graph = context.newDependencyGraph(compileContextData, code, binary)
+		}
+		checker.checkDependencyGraph(compileContextData, graph)
+	}
+}
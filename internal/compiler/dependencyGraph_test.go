@@ -0,0 +1,81 @@
+package compiler
+
+import (
+	"bytes"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func analyze(t *testing.T, inputCode string) *DependencyGraph {
+	t.Helper()
+	compilerContextData := newMockedCompiler(simulateGoodRenderingCallback)
+	compilerContextData.config.SrcDirs = []string{"./testdata"}
+	graph, err := compilerContextData.compileContext.Analyze(compilerContextData, inputCode, "myBinary")
+	assert.NilError(t, err)
+	return graph
+}
+
+func TestAnalyzeFunctionNotFound(t *testing.T) {
+	compilerContextData := newMockedCompiler(simulateGoodRenderingCallback)
+	graph, err := compilerContextData.compileContext.Analyze(
+		compilerContextData, "MyPackage::function", "myBinary",
+	)
+	assert.Error(t, err, "function not found: MyPackage::function in any srcDirs []")
+	assert.Assert(t, graph == nil)
+}
+
+func TestAnalyzeEdges(t *testing.T) {
+	graph := analyze(t, "# FUNCTIONS\nMyPackage::useDependentFunction\nMyCompletePackage::function\n")
+	assert.DeepEqual(t, []DependencyEdge{
+		{From: "myBinary", To: "MyCompletePackage::function", Kind: DependencyEdgeKindCall},
+		{From: "myBinary", To: "MyPackage::useDependentFunction", Kind: DependencyEdgeKindCall},
+		{From: "MyCompletePackage::function", To: "testdata/MyCompletePackage/_.sh", Kind: DependencyEdgeKindSpecialFile},
+		{From: "MyCompletePackage::function", To: "testdata/MyCompletePackage/ZZZ.sh", Kind: DependencyEdgeKindSpecialFile},
+		{From: "MyPackage::useDependentFunction", To: "MyPackage::function", Kind: DependencyEdgeKindCall},
+	}, graph.Edges)
+}
+
+func TestReferenceChain(t *testing.T) {
+	graph := analyze(t, "# FUNCTIONS\nMyPackage::useDependentFunction\nMyCompletePackage::function\n")
+	t.Run("indirect reference", func(t *testing.T) {
+		chain, err := graph.ReferenceChain("MyPackage::function")
+		assert.NilError(t, err)
+		assert.Equal(
+			t,
+			"myBinary -[call]-> MyPackage::useDependentFunction -[call]-> MyPackage::function",
+			graph.FormatReferenceChain(chain),
+		)
+	})
+	t.Run("special file", func(t *testing.T) {
+		chain, err := graph.ReferenceChain("testdata/MyCompletePackage/ZZZ.sh")
+		assert.NilError(t, err)
+		assert.Equal(
+			t,
+			"myBinary -[call]-> MyCompletePackage::function -[specialFile]-> MyCompletePackage/ZZZ.sh",
+			graph.FormatReferenceChain(chain),
+		)
+	})
+	t.Run("not included", func(t *testing.T) {
+		_, err := graph.ReferenceChain("MyPackage::unknown")
+		assert.Error(t, err, "function MyPackage::unknown is not included in binary myBinary")
+	})
+}
+
+func TestWriteDependencyGraphs(t *testing.T) {
+	graph := analyze(t, "# FUNCTIONS\nMyPackage::useDependentFunction\nMyCompletePackage::function\n")
+	for _, format := range []string{"dot", "mermaid", "json"} {
+		t.Run(format, func(t *testing.T) {
+			var output bytes.Buffer
+			err := WriteDependencyGraphs(&output, format, []*DependencyGraph{graph})
+			assert.NilError(t, err)
+			golden.Assert(t, output.String(), "expectedTestWriteDependencyGraphs."+format)
+		})
+	}
+	t.Run("unknown format", func(t *testing.T) {
+		var output bytes.Buffer
+		err := WriteDependencyGraphs(&output, "svg", []*DependencyGraph{graph})
+		assert.Error(t, err, "unknown dependency graph format: svg")
+	})
+}
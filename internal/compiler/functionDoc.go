@@ -0,0 +1,216 @@
+package compiler
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var shdocTagRegexp = regexp.MustCompile(`^#[[:blank:]]*@(?P<tag>[a-zA-Z]+)[[:blank:]]*(?P<value>.*)$`)
+
+// FunctionDoc is the documentation of a framework function extracted from
+// the shdoc comments (@description, @arg, @exitcode, ...) preceding its definition
+type FunctionDoc struct {
+	FunctionName string   `json:"functionName"`
+	Namespace    string   `json:"namespace"`
+	SrcFile      string   `json:"srcFile"`
+	Description  string   `json:"description"`
+	Args         []string `json:"args"`
+	Options      []string `json:"options"`
+	Env          []string `json:"env"`
+	ExitCodes    []string `json:"exitCodes"`
+	Stdin        []string `json:"stdin"`
+	Stdout       []string `json:"stdout"`
+	Stderr       []string `json:"stderr"`
+	See          []string `json:"see"`
+	Example      string   `json:"example"`
+	// Requires are the functions referenced using @require annotation
+	Requires []string `json:"requires"`
+	// Calls are the framework functions invoked by the function
+	Calls []string `json:"calls"`
+}
+
+// GetFunctionNamespace returns the namespace of the function (eg: Foo::bar for Foo::bar::baz)
+func GetFunctionNamespace(functionName string) string {
+	index := strings.LastIndex(functionName, "::")
+	if index < 0 {
+		return ""
+	}
+	return functionName[:index]
+}
+
+// ListSrcDirsFunctions returns the source file of each framework function found in the srcDirs,
+// the function file is the one of the first srcDir containing it as the compiler does
+func ListSrcDirsFunctions(srcDirs []string) (map[string]string, error) {
+	functions := map[string]string{}
+	for _, srcDir := range srcDirs {
+		err := filepath.WalkDir(srcDir, func(path string, dirEntry fs.DirEntry, err error) error {
+			if err != nil || dirEntry.IsDir() || filepath.Ext(path) != ".sh" {
+				return err
+			}
+			relativePath, err := filepath.Rel(srcDir, path)
+			if err != nil {
+				return err
+			}
+			functionName := convertPathToFunctionName(relativePath)
+			if _, exists := functions[functionName]; !exists && IsBashFrameworkFunction([]byte(functionName)) {
+				functions[functionName] = path
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return functions, nil
+}
+
+// convertPathToFunctionName is the opposite of convertFunctionNameToPath
+func convertPathToFunctionName(relativePath string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(filepath.ToSlash(relativePath), ".sh"), "/", "::")
+}
+
+// ParseFunctionDoc extracts the documentation of the function from its source file
+func ParseFunctionDoc(functionName string, srcFile string) (*FunctionDoc, error) {
+	content, err := os.ReadFile(srcFile)
+	if err != nil {
+		return nil, err
+	}
+	code := string(content)
+	functionDoc := &FunctionDoc{
+		FunctionName: functionName,
+		Namespace:    GetFunctionNamespace(functionName),
+		SrcFile:      srcFile,
+		Description:  "",
+		Args:         []string{},
+		Options:      []string{},
+		Env:          []string{},
+		ExitCodes:    []string{},
+		Stdin:        []string{},
+		Stdout:       []string{},
+		Stderr:       []string{},
+		See:          []string{},
+		Example:      "",
+		Requires:     []string{},
+		Calls:        []string{},
+	}
+	var requirements []Requirement
+	requirements, code = extractRequirements(code)
+	functionDoc.Requires = getRequiredFunctionNames(requirements)
+	functionDoc.parseComments(getFunctionCommentBlock(code, functionName))
+	for _, calledFunction := range extractFrameworkFunctionReferences(code) {
+		if calledFunction != functionName && !slices.Contains(functionDoc.Requires, calledFunction) {
+			functionDoc.Calls = append(functionDoc.Calls, calledFunction)
+		}
+	}
+	return functionDoc, nil
+}
+
+// getFunctionCommentBlock returns the comment lines directly preceding the function definition,
+// the first comment block of the file if the definition is not found
+func getFunctionCommentBlock(code string, functionName string) []string {
+	quotedFunctionName := regexp.QuoteMeta(functionName)
+	definitionRegexp := regexp.MustCompile(
+		`^[[:blank:]]*(function[[:blank:]]+` + quotedFunctionName + `([[:blank:](]|$)|` +
+			quotedFunctionName + `[[:blank:]]*\(\))`,
+	)
+	var firstBlock []string
+	block := []string{}
+	for _, line := range strings.Split(code, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		if definitionRegexp.MatchString(line) {
+			return block
+		}
+		if strings.HasPrefix(trimmedLine, "#") && !strings.HasPrefix(trimmedLine, "#!") {
+			block = append(block, trimmedLine)
+			continue
+		}
+		if firstBlock == nil && len(block) > 0 {
+			firstBlock = block
+		}
+		block = []string{}
+	}
+	return firstBlock
+}
+
+// parseComments interprets the shdoc tags, the lines following a tag
+// without any tag are the continuation of this tag
+func (functionDoc *FunctionDoc) parseComments(commentLines []string) {
+	var currentValue *string
+	var currentList *[]string
+	for _, line := range commentLines {
+		if shellcheckDirectiveRegexp.MatchString(line) {
+			continue
+		}
+		matches := shdocTagRegexp.FindStringSubmatch(line)
+		if matches == nil {
+			text := strings.TrimPrefix(strings.TrimPrefix(line, "#"), " ")
+			switch {
+			case currentValue != nil:
+				*currentValue += "\n" + text
+			case currentList != nil && len(*currentList) > 0:
+				(*currentList)[len(*currentList)-1] += " " + strings.TrimSpace(text)
+			}
+			continue
+		}
+		value := matches[shdocTagRegexp.SubexpIndex("value")]
+		currentValue = nil
+		currentList = functionDoc.getTagList(matches[shdocTagRegexp.SubexpIndex("tag")])
+		if currentList != nil {
+			*currentList = append(*currentList, value)
+			continue
+		}
+		switch matches[shdocTagRegexp.SubexpIndex("tag")] {
+		case "description":
+			currentValue = &functionDoc.Description
+		case "example":
+			currentValue = &functionDoc.Example
+		default:
+			continue
+		}
+		*currentValue = value
+	}
+	functionDoc.Description = strings.TrimSpace(functionDoc.Description)
+	functionDoc.Example = strings.Trim(functionDoc.Example, "\n")
+}
+
+func (functionDoc *FunctionDoc) getTagList(tag string) *[]string {
+	switch tag {
+	case "arg":
+		return &functionDoc.Args
+	case "option":
+		return &functionDoc.Options
+	case "env":
+		return &functionDoc.Env
+	case "exitcode":
+		return &functionDoc.ExitCodes
+	case "stdin":
+		return &functionDoc.Stdin
+	case "stdout":
+		return &functionDoc.Stdout
+	case "stderr":
+		return &functionDoc.Stderr
+	case "see":
+		return &functionDoc.See
+	}
+	return nil
+}
+
+// ReplaceFrameworkFunctionReferences replaces each framework function name found in the text
+// by the result of replace, the other parts of the text are transformed using escape
+func ReplaceFrameworkFunctionReferences(
+	text string, replace func(functionName string) string, escape func(text string) string,
+) string {
+	var result strings.Builder
+	lastIndex := 0
+	for _, match := range bashFrameworkFunctionRegexp.FindAllStringIndex(text, -1) {
+		result.WriteString(escape(text[lastIndex:match[0]]))
+		result.WriteString(replace(text[match[0]:match[1]]))
+		lastIndex = match[1]
+	}
+	result.WriteString(escape(text[lastIndex:]))
+	return result.String()
+}
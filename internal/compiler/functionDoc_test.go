@@ -0,0 +1,84 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestListSrcDirsFunctions(t *testing.T) {
+	projectSrcDir := t.TempDir()
+	vendorSrcDir := t.TempDir()
+	for _, file := range []string{
+		filepath.Join(projectSrcDir, "Log", "info.sh"),
+		filepath.Join(projectSrcDir, "Array", "Sub", "wrap.sh"),
+		filepath.Join(projectSrcDir, "Log", "README.md"),
+		filepath.Join(projectSrcDir, "main.sh"),
+		filepath.Join(vendorSrcDir, "Log", "info.sh"),
+		filepath.Join(vendorSrcDir, "Log", "warning.sh"),
+	} {
+		assert.NilError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		assert.NilError(t, os.WriteFile(file, []byte{}, 0o600))
+	}
+	functions, err := ListSrcDirsFunctions([]string{projectSrcDir, vendorSrcDir})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{
+		"Array::Sub::wrap": filepath.Join(projectSrcDir, "Array", "Sub", "wrap.sh"),
+		"Log::info":        filepath.Join(projectSrcDir, "Log", "info.sh"),
+		"Log::warning":     filepath.Join(vendorSrcDir, "Log", "warning.sh"),
+	}, functions)
+}
+
+func TestParseFunctionDoc(t *testing.T) {
+	srcFile := filepath.Join(t.TempDir(), "display.sh")
+	code := "#!/usr/bin/env bash\n" +
+		"# shellcheck disable=SC2034\n" +
+		"\n" +
+		"# @description display a message\n" +
+		"# using Log::info\n" +
+		"# @arg $1 message:String the message\n" +
+		"#   on several lines\n" +
+		"# @env DISPLAY_LEVEL int the level\n" +
+		"# @exitcode 1 if message is empty\n" +
+		"# @stdout the message\n" +
+		"# @see Log::warning\n" +
+		"# @example\n" +
+		"#   Log::display \"hello\"\n" +
+		"# @require Log::requireLevel\n" +
+		"Log::display() {\n" +
+		"  Log::info \"$1\"\n" +
+		"  Log::display \"$1\"\n" +
+		"}\n"
+	assert.NilError(t, os.WriteFile(srcFile, []byte(code), 0o600))
+
+	functionDoc, err := ParseFunctionDoc("Log::display", srcFile)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &FunctionDoc{
+		FunctionName: "Log::display",
+		Namespace:    "Log",
+		SrcFile:      srcFile,
+		Description:  "display a message\nusing Log::info",
+		Args:         []string{"$1 message:String the message on several lines"},
+		Options:      []string{},
+		Env:          []string{"DISPLAY_LEVEL int the level"},
+		ExitCodes:    []string{"1 if message is empty"},
+		Stdin:        []string{},
+		Stdout:       []string{"the message"},
+		Stderr:       []string{},
+		See:          []string{"Log::warning"},
+		Example:      "  Log::display \"hello\"",
+		Requires:     []string{"Log::requireLevel"},
+		Calls:        []string{"Log::info"},
+	}, functionDoc)
+}
+
+func TestReplaceFrameworkFunctionReferences(t *testing.T) {
+	result := ReplaceFrameworkFunctionReferences(
+		"use Log::info & Array::contains",
+		func(functionName string) string { return "[" + functionName + "]" },
+		func(text string) string { return "<" + text + ">" },
+	)
+	assert.Equal(t, "<use >[Log::info]< & >[Array::contains]<>", result)
+}
@@ -0,0 +1,135 @@
+package compiler
+
+import (
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+var (
+	shellcheckDirectiveRegexp = regexp.MustCompile(`^#[[:blank:]]*shellcheck[[:blank:]]`)
+	licenseRegexp             = regexp.MustCompile(`(?i)\b(copyright|license|spdx-license-identifier)\b`)
+	shdocAnnotationRegexp     = regexp.MustCompile(`^#[[:blank:]]*@[a-z]+`)
+)
+
+// SizeReduction describes the size of the compiled code before and after
+// the removal of comments and blank lines in release mode
+type SizeReduction struct {
+	LinesBefore int `json:"linesBefore"`
+	LinesAfter  int `json:"linesAfter"`
+	BytesBefore int `json:"bytesBefore"`
+	BytesAfter  int `json:"bytesAfter"`
+}
+
+// Percent returns the percentage of bytes removed
+func (sizeReduction *SizeReduction) Percent() float64 {
+	if sizeReduction.BytesBefore == 0 {
+		return 0
+	}
+	return float64(sizeReduction.BytesBefore-sizeReduction.BytesAfter) * 100 / float64(sizeReduction.BytesBefore)
+}
+
+// StripForRelease removes comment lines (including shdoc blocks) and blank lines from the compiled code.
+// The shebang, the shellcheck directives and the license headers are kept,
+// as well as the content of heredocs and of multi-line strings.
+// The source map is updated accordingly.
+func StripForRelease(code string, sourceMap *SourceMap) (string, *SourceMap, *SizeReduction, error) {
+	file, err := parseBashCode(code)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	protectedLines := getMultiLineTextLines(file)
+	lines := strings.SplitAfter(code, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	keptLines := make([]int, 0, len(lines))
+	var newCode strings.Builder
+	keepLine := func(index int) {
+		newCode.WriteString(lines[index])
+		keptLines = append(keptLines, index+1)
+	}
+	for index := 0; index < len(lines); index++ {
+		if protectedLines[index+1] {
+			keepLine(index)
+			continue
+		}
+		line := strings.TrimSpace(lines[index])
+		if line == "" {
+			continue
+		}
+		if !isStrippableCommentLine(index, line) {
+			keepLine(index)
+			continue
+		}
+		blockEnd := getCommentBlockEnd(lines, index, protectedLines)
+		if isLicenseHeader(lines[index:blockEnd]) {
+			for ; index < blockEnd; index++ {
+				keepLine(index)
+			}
+			index--
+		}
+	}
+
+	sizeReduction := &SizeReduction{
+		LinesBefore: len(lines),
+		LinesAfter:  len(keptLines),
+		BytesBefore: len(code),
+		BytesAfter:  newCode.Len(),
+	}
+	if sourceMap != nil {
+		sourceMap = sourceMap.keepLines(keptLines)
+	}
+	return newCode.String(), sourceMap, sizeReduction, nil
+}
+
+func isStrippableCommentLine(index int, line string) bool {
+	if !strings.HasPrefix(line, "#") {
+		return false
+	}
+	if index == 0 && strings.HasPrefix(line, "#!") {
+		return false
+	}
+	return !shellcheckDirectiveRegexp.MatchString(line)
+}
+
+// getMultiLineTextLines returns the lines (1-based) belonging to a literal text
+// spanning several lines (heredoc, multi-line string), they should be kept as is
+func getMultiLineTextLines(file *syntax.File) map[int]bool {
+	protectedLines := map[int]bool{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node.(type) {
+		case *syntax.Lit, *syntax.SglQuoted:
+			for line := node.Pos().Line(); line <= node.End().Line(); line++ {
+				protectedLines[int(line)] = true
+			}
+		}
+		return true
+	})
+	return protectedLines
+}
+
+// getCommentBlockEnd returns the index of the first line following the
+// block of comment lines starting at index
+func getCommentBlockEnd(lines []string, index int, protectedLines map[int]bool) int {
+	for index < len(lines) &&
+		!protectedLines[index+1] && strings.HasPrefix(strings.TrimSpace(lines[index]), "#") {
+		index++
+	}
+	return index
+}
+
+// isLicenseHeader indicates if the comment block mentions a copyright or a license,
+// shdoc blocks are not considered as license headers
+func isLicenseHeader(commentLines []string) bool {
+	isLicense := false
+	for _, line := range commentLines {
+		line = strings.TrimSpace(line)
+		if shdocAnnotationRegexp.MatchString(line) {
+			return false
+		}
+		isLicense = isLicense || licenseRegexp.MatchString(line)
+	}
+	return isLicense
+}
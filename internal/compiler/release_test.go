@@ -0,0 +1,70 @@
+package compiler
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestStripForRelease(t *testing.T) {
+	code := "#!/usr/bin/env bash\n" +
+		"# Copyright (c) 2024 Me\n" +
+		"# my binary header\n" +
+		"\n" +
+		"# @description does something\n" +
+		"# @arg $1 string\n" +
+		"My::func() {\n" +
+		"  # shellcheck disable=SC2034\n" +
+		"  local var=1\n" +
+		"\n" +
+		"  # a comment\n" +
+		"  cat <<EOF\n" +
+		"# heredoc line\n" +
+		"\n" +
+		"EOF\n" +
+		"  echo \"multi\n" +
+		"\n" +
+		"# line\" # trailing comment\n" +
+		"}\n"
+	expectedCode := "#!/usr/bin/env bash\n" +
+		"# Copyright (c) 2024 Me\n" +
+		"# my binary header\n" +
+		"My::func() {\n" +
+		"  # shellcheck disable=SC2034\n" +
+		"  local var=1\n" +
+		"  cat <<EOF\n" +
+		"# heredoc line\n" +
+		"\n" +
+		"EOF\n" +
+		"  echo \"multi\n" +
+		"\n" +
+		"# line\" # trailing comment\n" +
+		"}\n"
+	sourceMap := &SourceMap{
+		File:      "",
+		LineCount: 19,
+		Mappings: []SourceMapping{
+			{StartLine: 1, EndLine: 6, Kind: SourceMappingKindTemplate, SrcFile: "binFile.gtpl"},
+			{
+				StartLine: 7, EndLine: 19, Kind: SourceMappingKindFunction,
+				FunctionName: "My::func", SrcFile: "func.sh", SrcLine: 1,
+			},
+		},
+	}
+
+	newCode, newSourceMap, sizeReduction, err := StripForRelease(code, sourceMap)
+	assert.NilError(t, err)
+	assert.Equal(t, expectedCode, newCode)
+	assert.DeepEqual(t, &SizeReduction{
+		LinesBefore: 19, LinesAfter: 14, BytesBefore: len(code), BytesAfter: len(expectedCode),
+	}, sizeReduction)
+	assert.Equal(t, 14, newSourceMap.LineCount)
+	location, err := newSourceMap.Locate(7)
+	assert.NilError(t, err)
+	assert.Equal(t, "func.sh:6 (function My::func)", location.String())
+}
+
+func TestStripForReleaseInvalidCode(t *testing.T) {
+	_, _, _, err := StripForRelease("if true; then\n", nil)
+	assert.ErrorContains(t, err, "must be followed by a statement list")
+}
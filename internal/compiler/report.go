@@ -0,0 +1,149 @@
+package compiler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/files"
+)
+
+type invalidInsertPositionError struct {
+	error
+	InsertPosition string
+}
+
+func (e *invalidInsertPositionError) Error() string {
+	return "invalid insert position: " + e.InsertPosition
+}
+
+var insertPositionNames = map[InsertPosition]string{
+	InsertPositionFirst:  "first",
+	InsertPositionMiddle: "middle",
+	InsertPositionLast:   "last",
+}
+
+func (insertPosition InsertPosition) String() string {
+	if name, ok := insertPositionNames[insertPosition]; ok {
+		return name
+	}
+	return fmt.Sprintf("InsertPosition(%d)", int8(insertPosition))
+}
+
+func (insertPosition InsertPosition) MarshalText() ([]byte, error) {
+	return []byte(insertPosition.String()), nil
+}
+
+func (insertPosition *InsertPosition) UnmarshalText(text []byte) error {
+	for position, name := range insertPositionNames {
+		if name == string(text) {
+			*insertPosition = position
+			return nil
+		}
+	}
+	return &invalidInsertPositionError{nil, string(text)}
+}
+
+// IncludedFunction describes a function (or a _.sh/ZZZ.sh special file)
+// included in the compiled code
+type IncludedFunction struct {
+	FunctionName   string         `json:"functionName"`
+	SrcFile        string         `json:"srcFile"`
+	InsertPosition InsertPosition `json:"insertPosition"`
+	// Requires lists the functions referenced using @require annotation
+	Requires []string `json:"requires,omitempty"`
+}
+
+// EmbeddedResource describes a file or a directory embedded using @embed annotation
+type EmbeddedResource struct {
+	AsName   string `json:"asName"`
+	Resource string `json:"resource"`
+	// Checksum is the sha256 of the file or of the reproducible archive of the directory
+	Checksum string `json:"checksum"`
+}
+
+type embeddedResourcesProviderInterface interface {
+	getEmbeddedResources() map[string]string
+}
+
+// GetIncludedFunctions returns the functions included in the compiled code sorted by name
+func (context *CompileContextData) GetIncludedFunctions() []IncludedFunction {
+	includedFunctions := make([]IncludedFunction, 0, len(context.functionsMap))
+	for _, functionName := range getSortedFunctionNamesFromMap(context.functionsMap) {
+		functionInfo := context.functionsMap[functionName]
+		includedFunction := IncludedFunction{
+			FunctionName:   functionName,
+			SrcFile:        functionInfo.SrcFile,
+			InsertPosition: functionInfo.InsertPosition,
+			Requires:       nil,
+		}
+		requireAnnotation, err := functionInfo.getRequireAnnotation()
+		if err == nil && len(requireAnnotation.requiredFunctions) > 0 {
+			includedFunction.Requires = requireAnnotation.requiredFunctions
+		}
+		includedFunctions = append(includedFunctions, includedFunction)
+	}
+	return includedFunctions
+}
+
+// GetEmbeddedResources returns the resources embedded during the last compilation sorted by name
+func (context *CompileContextData) GetEmbeddedResources() ([]EmbeddedResource, error) {
+	embeddedResources := []EmbeddedResource{}
+	if context.compileContext == nil {
+		return embeddedResources, nil
+	}
+	for _, annotationProcessor := range context.compileContext.annotationProcessors {
+		provider, ok := annotationProcessor.(embeddedResourcesProviderInterface)
+		if !ok {
+			continue
+		}
+		for asName, resource := range provider.getEmbeddedResources() {
+			checksum, err := EmbeddedResourceChecksum(resource)
+			if err != nil {
+				return nil, err
+			}
+			embeddedResources = append(embeddedResources, EmbeddedResource{
+				AsName:   asName,
+				Resource: resource,
+				Checksum: checksum,
+			})
+		}
+	}
+	sort.Slice(embeddedResources, func(i, j int) bool {
+		return embeddedResources[i].AsName < embeddedResources[j].AsName
+	})
+	return embeddedResources, nil
+}
+
+// GetDeprecatedFunctionUsages returns the deprecated functions included during the last compilation sorted by name
+func (context *CompileContextData) GetDeprecatedFunctionUsages() []DeprecatedFunctionUsage {
+	usages := []DeprecatedFunctionUsage{}
+	if context.compileContext == nil {
+		return usages
+	}
+	for _, annotationProcessor := range context.compileContext.annotationProcessors {
+		if provider, ok := annotationProcessor.(deprecatedFunctionsProviderInterface); ok {
+			usages = append(usages, provider.getDeprecatedFunctionUsages()...)
+		}
+	}
+	return usages
+}
+
+// EmbeddedResourceChecksum returns the sha256 of the file or of the reproducible archive of the directory
+func EmbeddedResourceChecksum(resource string) (string, error) {
+	fileInfo, err := os.Stat(resource)
+	if err != nil {
+		return "", err
+	}
+	if !fileInfo.IsDir() {
+		return files.ChecksumFromFile(resource)
+	}
+	hash := sha256.New()
+	err = createDirectoryArchive(resource, hash)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
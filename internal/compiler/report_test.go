@@ -0,0 +1,89 @@
+package compiler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestGetIncludedFunctions(t *testing.T) {
+	compilerContextData := newMockedCompiler(simulateGoodRenderingCallback)
+	compilerContextData.config.SrcDirs = []string{"./testdata"}
+	_, err := compilerContextData.compileContext.computeFunctions(
+		compilerContextData, "# FUNCTIONS\nMyPackage::useDependentFunction\nMyCompletePackage::function\n",
+	)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []IncludedFunction{
+		{
+			FunctionName:   "MyCompletePackage::function",
+			SrcFile:        "testdata/MyCompletePackage/function.sh",
+			InsertPosition: InsertPositionMiddle,
+			Requires:       nil,
+		},
+		{
+			FunctionName:   "MyPackage::function",
+			SrcFile:        "testdata/MyPackage/function.sh",
+			InsertPosition: InsertPositionMiddle,
+			Requires:       nil,
+		},
+		{
+			FunctionName:   "MyPackage::useDependentFunction",
+			SrcFile:        "testdata/MyPackage/useDependentFunction.sh",
+			InsertPosition: InsertPositionMiddle,
+			Requires:       nil,
+		},
+		{
+			FunctionName:   "testdata/MyCompletePackage/ZZZ.sh",
+			SrcFile:        "testdata/MyCompletePackage/ZZZ.sh",
+			InsertPosition: InsertPositionLast,
+			Requires:       nil,
+		},
+		{
+			FunctionName:   "testdata/MyCompletePackage/_.sh",
+			SrcFile:        "testdata/MyCompletePackage/_.sh",
+			InsertPosition: InsertPositionFirst,
+			Requires:       nil,
+		},
+	}, compilerContextData.GetIncludedFunctions())
+}
+
+func TestInsertPositionJson(t *testing.T) {
+	content, err := json.Marshal([]InsertPosition{InsertPositionFirst, InsertPositionMiddle, InsertPositionLast})
+	assert.NilError(t, err)
+	assert.Equal(t, `["first","middle","last"]`, string(content))
+
+	var insertPositions []InsertPosition
+	err = json.Unmarshal(content, &insertPositions)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []InsertPosition{InsertPositionFirst, InsertPositionMiddle, InsertPositionLast}, insertPositions)
+
+	err = json.Unmarshal([]byte(`["unknown"]`), &insertPositions)
+	assert.Error(t, err, "invalid insert position: unknown")
+}
+
+func TestGetEmbeddedResources(t *testing.T) {
+	embedProcessor := getEmbedProcessorMocked(nil)
+	embedProcessor.embedMap["file"] = "testdata/MyPackage/function.sh"
+	embedProcessor.embedMap["dir"] = "testdata/MyPackage"
+	compileContextData := getCompileContextData(nil, nil)
+	compileContextData.compileContext.annotationProcessors = []AnnotationProcessorInterface{embedProcessor}
+
+	embeddedResources, err := compileContextData.GetEmbeddedResources()
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(embeddedResources))
+	assert.Equal(t, "dir", embeddedResources[0].AsName)
+	assert.Equal(t, "testdata/MyPackage", embeddedResources[0].Resource)
+	assert.Equal(t, 64, len(embeddedResources[0].Checksum))
+	fileChecksum, err := EmbeddedResourceChecksum("testdata/MyPackage/function.sh")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, EmbeddedResource{
+		AsName:   "file",
+		Resource: "testdata/MyPackage/function.sh",
+		Checksum: fileChecksum,
+	}, embeddedResources[1])
+
+	embedProcessor.embedMap["missing"] = "testdata/missing"
+	_, err = compileContextData.GetEmbeddedResources()
+	assert.ErrorContains(t, err, "no such file or directory")
+}
@@ -0,0 +1,209 @@
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fchastanet/bash-compiler/internal/render"
+)
+
+// SourceMapFileSuffix is added to the target file to get its source map file
+const SourceMapFileSuffix = ".map"
+
+type SourceMappingKind string
+
+const (
+	SourceMappingKindFunction     SourceMappingKind = render.SourceMapKindFunction
+	SourceMappingKindIncludedFile SourceMappingKind = render.SourceMapKindIncludedFile
+	// SourceMappingKindTemplate lines generated by the template of the binary
+	SourceMappingKindTemplate SourceMappingKind = "template"
+)
+
+type lineOutOfRangeError struct {
+	error
+	Line      int
+	LineCount int
+}
+
+func (e *lineOutOfRangeError) Error() string {
+	return fmt.Sprintf("line %d is out of range, the binary has %d lines", e.Line, e.LineCount)
+}
+
+// SourceMapping links a range of lines of the compiled code to its origin
+type SourceMapping struct {
+	// StartLine and EndLine are the first and last lines (1-based) of the range in the compiled code
+	StartLine    int               `json:"startLine"`
+	EndLine      int               `json:"endLine"`
+	Kind         SourceMappingKind `json:"kind"`
+	FunctionName string            `json:"functionName,omitempty"`
+	SrcFile      string            `json:"srcFile"`
+	// SrcLine is the line of SrcFile matching StartLine, 0 if unknown (eg: template)
+	SrcLine int `json:"srcLine,omitempty"`
+}
+
+// SourceMap links each line of a compiled binary to the file it comes from
+type SourceMap struct {
+	File      string          `json:"file"`
+	LineCount int             `json:"lineCount"`
+	Mappings  []SourceMapping `json:"mappings"`
+}
+
+// SourceLocation is the origin of a line of a compiled binary
+type SourceLocation struct {
+	Kind         SourceMappingKind `json:"kind"`
+	FunctionName string            `json:"functionName,omitempty"`
+	SrcFile      string            `json:"srcFile"`
+	SrcLine      int               `json:"srcLine,omitempty"`
+}
+
+func (location *SourceLocation) String() string {
+	var description strings.Builder
+	description.WriteString(location.SrcFile)
+	if location.SrcLine > 0 {
+		fmt.Fprintf(&description, ":%d", location.SrcLine)
+	}
+	fmt.Fprintf(&description, " (%s", location.Kind)
+	if location.FunctionName != "" {
+		fmt.Fprintf(&description, " %s", location.FunctionName)
+	}
+	description.WriteString(")")
+	return description.String()
+}
+
+type sourceMapFrame struct {
+	marker      *render.SourceMapMarker
+	nextSrcLine int
+}
+
+// extractSourceMap removes the markers added by render.AddSourceMapMarkers and computes
+// the source map of the resulting code, lines outside of any marker come from templateFile.
+// Markers can be indented by the formatter.
+// If inlineMarkers is true, a comment indicating the origin is kept instead of each begin marker.
+func extractSourceMap(code string, templateFile string, inlineMarkers bool) (string, *SourceMap) {
+	sourceMap := &SourceMap{File: "", LineCount: 0, Mappings: []SourceMapping{}}
+	var newCode strings.Builder
+	stack := []*sourceMapFrame{}
+	lines := strings.SplitAfter(code, "\n")
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		isMarker, marker := render.ParseSourceMapMarker(strings.TrimSpace(line))
+		if isMarker && marker == nil {
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		if isMarker {
+			if len(stack) > 0 {
+				// the nested code replaces one line of the parent (eg: include directive)
+				stack[len(stack)-1].nextSrcLine++
+			}
+			if inlineMarkers {
+				newCode.WriteString(fmt.Sprintf("# source: %s:%d\n", marker.SrcFile, marker.SrcLine))
+				sourceMap.addLine(stack, templateFile)
+			}
+			stack = append(stack, &sourceMapFrame{marker: marker, nextSrcLine: marker.SrcLine})
+			continue
+		}
+		newCode.WriteString(line)
+		sourceMap.addLine(stack, templateFile)
+	}
+	return newCode.String(), sourceMap
+}
+
+// addLine adds the next line of the compiled code to the source map
+func (sourceMap *SourceMap) addLine(stack []*sourceMapFrame, templateFile string) {
+	sourceMap.LineCount++
+	mapping := SourceMapping{
+		StartLine:    sourceMap.LineCount,
+		EndLine:      sourceMap.LineCount,
+		Kind:         SourceMappingKindTemplate,
+		FunctionName: "",
+		SrcFile:      templateFile,
+		SrcLine:      0,
+	}
+	if len(stack) > 0 {
+		frame := stack[len(stack)-1]
+		mapping.Kind = SourceMappingKind(frame.marker.Kind)
+		mapping.FunctionName = frame.marker.Name
+		mapping.SrcFile = frame.marker.SrcFile
+		mapping.SrcLine = frame.nextSrcLine
+		frame.nextSrcLine++
+	}
+	sourceMap.appendMapping(mapping)
+}
+
+// appendMapping adds the mapping of one line, merging it with the previous mapping
+// if the line follows it in the same source
+func (sourceMap *SourceMap) appendMapping(mapping SourceMapping) {
+	if len(sourceMap.Mappings) > 0 {
+		previous := &sourceMap.Mappings[len(sourceMap.Mappings)-1]
+		if previous.Kind == mapping.Kind && previous.FunctionName == mapping.FunctionName &&
+			previous.SrcFile == mapping.SrcFile && previous.EndLine+1 == mapping.StartLine &&
+			(previous.SrcLine == 0) == (mapping.SrcLine == 0) &&
+			(mapping.SrcLine == 0 || previous.SrcLine+previous.EndLine-previous.StartLine+1 == mapping.SrcLine) {
+			previous.EndLine = mapping.EndLine
+			return
+		}
+	}
+	sourceMap.Mappings = append(sourceMap.Mappings, mapping)
+}
+
+// keepLines computes the source map of the code in which only the given lines (1-based, sorted) are kept
+func (sourceMap *SourceMap) keepLines(keptLines []int) *SourceMap {
+	newSourceMap := &SourceMap{File: sourceMap.File, LineCount: 0, Mappings: []SourceMapping{}}
+	for _, line := range keptLines {
+		newSourceMap.LineCount++
+		location, err := sourceMap.Locate(line)
+		if err != nil {
+			continue
+		}
+		newSourceMap.appendMapping(SourceMapping{
+			StartLine:    newSourceMap.LineCount,
+			EndLine:      newSourceMap.LineCount,
+			Kind:         location.Kind,
+			FunctionName: location.FunctionName,
+			SrcFile:      location.SrcFile,
+			SrcLine:      location.SrcLine,
+		})
+	}
+	return newSourceMap
+}
+
+// Locate returns the origin of the line (1-based) of the compiled code
+func (sourceMap *SourceMap) Locate(line int) (*SourceLocation, error) {
+	for _, mapping := range sourceMap.Mappings {
+		if line < mapping.StartLine || line > mapping.EndLine {
+			continue
+		}
+		location := &SourceLocation{
+			Kind:         mapping.Kind,
+			FunctionName: mapping.FunctionName,
+			SrcFile:      mapping.SrcFile,
+			SrcLine:      0,
+		}
+		if mapping.SrcLine > 0 {
+			location.SrcLine = mapping.SrcLine + line - mapping.StartLine
+		}
+		return location, nil
+	}
+	return nil, &lineOutOfRangeError{nil, line, sourceMap.LineCount}
+}
+
+// LoadSourceMap loads the source map generated beside the target file
+func LoadSourceMap(targetFile string) (*SourceMap, error) {
+	content, err := os.ReadFile(targetFile + SourceMapFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	sourceMap := &SourceMap{} //nolint:exhaustruct // loaded from json
+	err = json.Unmarshal(content, sourceMap)
+	if err != nil {
+		return nil, err
+	}
+	return sourceMap, nil
+}
@@ -0,0 +1,67 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/render"
+	"gotest.tools/v3/assert"
+)
+
+func getCodeWithSourceMapMarkers() string {
+	return "#!/usr/bin/env bash\n" +
+		"# FUNCTIONS\n" +
+		render.AddSourceMapMarkers(
+			"\nLog::info() {\n  echo \"$1\"\n}\n", render.SourceMapKindFunction, "Log::info", "/src/Log/info.sh",
+		) +
+		"main() {\n" +
+		render.AddSourceMapMarkers(
+			"#!/usr/bin/env bash\n"+render.AddSourceMapMarkers("echo included\n", "includedFile", "", "/src/inc.sh")+
+				"Log::info \"done\"\n",
+			render.SourceMapKindIncludedFile, "", "/src/main.sh",
+		) +
+		"}\n"
+}
+
+func TestExtractSourceMap(t *testing.T) {
+	code, sourceMap := extractSourceMap(getCodeWithSourceMapMarkers(), "binFile.gtpl", false)
+	assert.Equal(t, "#!/usr/bin/env bash\n# FUNCTIONS\n\nLog::info() {\n  echo \"$1\"\n}\n"+
+		"main() {\n#!/usr/bin/env bash\necho included\nLog::info \"done\"\n}\n", code)
+	assert.Equal(t, 11, sourceMap.LineCount)
+	assert.DeepEqual(t, []SourceMapping{
+		{StartLine: 1, EndLine: 3, Kind: SourceMappingKindTemplate, SrcFile: "binFile.gtpl"},
+		{
+			StartLine: 4, EndLine: 6, Kind: SourceMappingKindFunction,
+			FunctionName: "Log::info", SrcFile: "/src/Log/info.sh", SrcLine: 2,
+		},
+		{StartLine: 7, EndLine: 8, Kind: SourceMappingKindTemplate, SrcFile: "binFile.gtpl"},
+		{StartLine: 9, EndLine: 9, Kind: SourceMappingKindIncludedFile, SrcFile: "/src/inc.sh", SrcLine: 1},
+		{StartLine: 10, EndLine: 10, Kind: SourceMappingKindIncludedFile, SrcFile: "/src/main.sh", SrcLine: 3},
+		{StartLine: 11, EndLine: 11, Kind: SourceMappingKindTemplate, SrcFile: "binFile.gtpl"},
+	}, sourceMap.Mappings)
+}
+
+func TestExtractSourceMapInlineMarkers(t *testing.T) {
+	code, sourceMap := extractSourceMap(getCodeWithSourceMapMarkers(), "binFile.gtpl", true)
+	assert.Equal(t, "#!/usr/bin/env bash\n# FUNCTIONS\n\n# source: /src/Log/info.sh:2\nLog::info() {\n  echo \"$1\"\n}\n"+
+		"main() {\n#!/usr/bin/env bash\n# source: /src/main.sh:2\n# source: /src/inc.sh:1\necho included\n"+
+		"Log::info \"done\"\n}\n", code)
+	location, err := sourceMap.Locate(5)
+	assert.NilError(t, err)
+	assert.Equal(t, "/src/Log/info.sh:2 (function Log::info)", location.String())
+}
+
+func TestSourceMapLocate(t *testing.T) {
+	_, sourceMap := extractSourceMap(getCodeWithSourceMapMarkers(), "binFile.gtpl", false)
+	location, err := sourceMap.Locate(5)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &SourceLocation{
+		Kind: SourceMappingKindFunction, FunctionName: "Log::info", SrcFile: "/src/Log/info.sh", SrcLine: 3,
+	}, location)
+
+	location, err = sourceMap.Locate(2)
+	assert.NilError(t, err)
+	assert.Equal(t, "binFile.gtpl (template)", location.String())
+
+	_, err = sourceMap.Locate(12)
+	assert.Error(t, err, "line 12 is out of range, the binary has 11 lines")
+}
@@ -0,0 +1,47 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/files"
+)
+
+// FunctionResolution describes which source file is used for a function
+type FunctionResolution struct {
+	FunctionName        string `json:"functionName"`
+	BinaryModelFilePath string `json:"binaryModelFilePath"`
+	// SrcFile is the file that is embedded, "" if not found in any srcDirs
+	SrcFile string `json:"srcFile"`
+	// ShadowedSrcFiles are the copies of the function in the following srcDirs
+	ShadowedSrcFiles []string `json:"shadowedSrcFiles"`
+	SrcDirs          []string `json:"srcDirs"`
+}
+
+// Which resolves the source file of the function the same way the compiler does
+// and lists the copies of this function shadowed by this file
+func (context CompileContext) Which(
+	compileContextData *CompileContextData,
+	functionName string,
+) *FunctionResolution {
+	relativeFilePath := convertFunctionNameToPath(functionName)
+	srcFile, _ := context.findFileInSrcDirs(compileContextData, relativeFilePath)
+	resolution := &FunctionResolution{
+		FunctionName:        functionName,
+		BinaryModelFilePath: "",
+		SrcFile:             srcFile,
+		ShadowedSrcFiles:    []string{},
+		SrcDirs:             compileContextData.config.SrcDirsExpanded,
+	}
+	if srcFile == "" {
+		return resolution
+	}
+	for _, srcDir := range compileContextData.config.SrcDirs {
+		shadowedSrcFile := os.ExpandEnv(filepath.Join(srcDir, relativeFilePath))
+		if shadowedSrcFile == srcFile || files.FileExists(shadowedSrcFile) != nil {
+			continue
+		}
+		resolution.ShadowedSrcFiles = append(resolution.ShadowedSrcFiles, shadowedSrcFile)
+	}
+	return resolution
+}
@@ -0,0 +1,37 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWhich(t *testing.T) {
+	overrideDir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(overrideDir, "MyPackage"), 0o755)
+	assert.NilError(t, err)
+	err = os.WriteFile(filepath.Join(overrideDir, "MyPackage", "function.sh"), []byte(""), 0o600)
+	assert.NilError(t, err)
+
+	compilerContextData := newMockedCompiler(simulateGoodRenderingCallback)
+	compilerContextData.config.SrcDirs = []string{overrideDir, "./testdata"}
+	compileContext := compilerContextData.compileContext
+
+	t.Run("shadowed function", func(t *testing.T) {
+		resolution := compileContext.Which(compilerContextData, "MyPackage::function")
+		assert.Equal(t, filepath.Join(overrideDir, "MyPackage", "function.sh"), resolution.SrcFile)
+		assert.DeepEqual(t, []string{"testdata/MyPackage/function.sh"}, resolution.ShadowedSrcFiles)
+	})
+	t.Run("function not shadowed", func(t *testing.T) {
+		resolution := compileContext.Which(compilerContextData, "MyPackage::useDependentFunction")
+		assert.Equal(t, "testdata/MyPackage/useDependentFunction.sh", resolution.SrcFile)
+		assert.DeepEqual(t, []string{}, resolution.ShadowedSrcFiles)
+	})
+	t.Run("function not found", func(t *testing.T) {
+		resolution := compileContext.Which(compilerContextData, "MyPackage::unknown")
+		assert.Equal(t, "", resolution.SrcFile)
+		assert.DeepEqual(t, []string{}, resolution.ShadowedSrcFiles)
+	})
+}
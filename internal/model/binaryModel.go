@@ -6,26 +6,60 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"sort"
 
 	"github.com/fchastanet/bash-compiler/internal/utils/logger"
 	"github.com/fchastanet/bash-compiler/internal/utils/structures"
 	"github.com/goccy/go-yaml"
 )
 
+// values of CompilerConfig.SyntaxValidation, the compilation fails by default
+// if the compiled code is not valid bash, warning only logs the error
+const (
+	SyntaxValidationError   = "error"
+	SyntaxValidationWarning = "warning"
+)
+
+// FormatterConfig options of the shell formatter applied to the compiled code
+type FormatterConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Indent is the number of spaces used for indentation, 0 means tabs
+	Indent           uint `yaml:"indent"`
+	BinaryNextLine   bool `yaml:"binaryNextLine"`
+	SwitchCaseIndent bool `yaml:"switchCaseIndent"`
+	SpaceRedirects   bool `yaml:"spaceRedirects"`
+	FunctionNextLine bool `yaml:"functionNextLine"`
+}
+
+// CustomAnnotationConfig declares an annotation processed using a template
+type CustomAnnotationConfig struct {
+	Name string `yaml:"name"`
+	// Regexp matches the annotation line, its named groups are provided to the template
+	Regexp string `yaml:"regexp"`
+	// TemplateName is the annotationsConfig entry providing the name of the template
+	TemplateName string `yaml:"templateName"`
+}
+
 type CompilerConfig struct {
-	AnnotationsConfig               structures.Dictionary `yaml:"annotationsConfig"`
-	TargetFile                      string                `yaml:"targetFile"`
-	RelativeRootDirBasedOnTargetDir string                `yaml:"relativeRootDirBasedOnTargetDir"`
-	CommandDefinitionFiles          []string              `yaml:"commandDefinitionFiles"`
-	TemplateFile                    string                `yaml:"templateFile"`
-	TemplateDirs                    []string              `yaml:"templateDirs"`
-	FunctionsIgnoreRegexpList       []string              `yaml:"functionsIgnoreRegexpList"`
-	SrcDirs                         []string              `yaml:"srcDirs"`
-	SrcDirsExpanded                 []string              `yaml:"-"`
-	IntermediateFilesDir            string                `yaml:"-"`
-	BinaryModelFilePath             string                `yaml:"-"`
-	BinaryModelBaseName             string                `yaml:"-"`
-	IntermediateFilesCount          int                   `yaml:"-"`
+	AnnotationsConfig               structures.Dictionary    `yaml:"annotationsConfig"`
+	TargetFile                      string                   `yaml:"targetFile"`
+	RelativeRootDirBasedOnTargetDir string                   `yaml:"relativeRootDirBasedOnTargetDir"`
+	CommandDefinitionFiles          []string                 `yaml:"commandDefinitionFiles"`
+	TemplateFile                    string                   `yaml:"templateFile"`
+	TemplateDirs                    []string                 `yaml:"templateDirs"`
+	FunctionsIgnoreRegexpList       []string                 `yaml:"functionsIgnoreRegexpList"`
+	SrcDirs                         []string                 `yaml:"srcDirs"`
+	SourceMap                       bool                     `yaml:"sourceMap"`
+	SourceMapMarkers                bool                     `yaml:"sourceMapMarkers"`
+	SyntaxValidation                string                   `yaml:"syntaxValidation"`
+	Formatter                       FormatterConfig          `yaml:"formatter"`
+	CustomAnnotations               []CustomAnnotationConfig `yaml:"customAnnotations"`
+	PostCompileCommands             []string                 `yaml:"postCompileCommands"`
+	SrcDirsExpanded                 []string                 `yaml:"-"`
+	IntermediateFilesDir            string                   `yaml:"-"`
+	BinaryModelFilePath             string                   `yaml:"-"`
+	BinaryModelBaseName             string                   `yaml:"-"`
+	IntermediateFilesCount          int                      `yaml:"-"`
 }
 
 func (compilerConfig *CompilerConfig) DebugSaveIntermediateFile(
@@ -43,10 +77,18 @@ func (compilerConfig *CompilerConfig) DebugSaveIntermediateFile(
 	}
 }
 
+// IsSourceMapEnabled returns true if the source map markers have to be added
+// to the generated code, needed by the source map file and the inline markers
+func (compilerConfig *CompilerConfig) IsSourceMapEnabled() bool {
+	return compilerConfig.SourceMap || compilerConfig.SourceMapMarkers
+}
+
 type BinaryModel struct {
 	CompilerConfig CompilerConfig        `yaml:"compilerConfig"`
 	Vars           structures.Dictionary `yaml:"vars"`
 	BinData        any                   `yaml:"binData"`
+	// LoadedFiles lists the model file and every file pulled in via extends
+	LoadedFiles []string `yaml:"-"`
 }
 
 type BinaryModelLoader struct{}
@@ -124,12 +166,37 @@ func (binaryModelContext *BinaryModelLoader) Load(
 		return nil, err
 	}
 
+	binaryModel.LoadedFiles = structures.MapKeys(loadedFiles)
+	sort.Strings(binaryModel.LoadedFiles)
 	binaryModelContext.setEnvVars(&binaryModel)
 	binaryModelContext.expandVars(&binaryModel)
 
 	return &binaryModel, err
 }
 
+// LoadExpandedModel returns the binary model merged with the files it extends, before KCL
+// transformation, with the environment variables expanded, the variables of the model taking
+// precedence. It allows to detect a change of the model without running KCL.
+func (*BinaryModelLoader) LoadExpandedModel(binaryModelFilePath string, referenceDir string) (string, error) {
+	modelMap := map[string]any{}
+	loadedFiles := map[string]string{}
+	err := loadModel(referenceDir, binaryModelFilePath, &modelMap, &loadedFiles, "")
+	if err != nil {
+		return "", err
+	}
+	content, err := yaml.Marshal(modelMap)
+	if err != nil {
+		return "", err
+	}
+	vars, _ := modelMap["vars"].(map[string]any)
+	return os.Expand(string(content), func(name string) string {
+		if value, ok := vars[name].(string); ok {
+			return value
+		}
+		return os.Getenv(name)
+	}), nil
+}
+
 func (*BinaryModelLoader) setEnvVars(binaryModel *BinaryModel) {
 	for key, value := range binaryModel.Vars {
 		if val, ok := value.(string); ok {
@@ -2,7 +2,9 @@ package model
 
 import (
 	"bytes"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"log/slog"
 	"os"
 	"path"
@@ -19,6 +21,13 @@ var kclBinFileSchema string
 //go:embed kcl/libs.k
 var kclLibs string
 
+// SchemaChecksum returns the checksum of the KCL schema applied to the binary models,
+// a change of the schema can change the defaults of the models
+func SchemaChecksum() string {
+	checksum := sha256.Sum256([]byte(kclBinFileSchema + "\n" + kclLibs))
+	return hex.EncodeToString(checksum[:])
+}
+
 func transformModel(tempYamlFile os.File, resultWriter *bytes.Buffer) (err error) {
 	tempKclTempDir, err := os.MkdirTemp("", "kcl")
 	if err != nil {
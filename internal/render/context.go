@@ -2,18 +2,29 @@ package render
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"log/slog"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
 	"github.com/fchastanet/bash-compiler/internal/utils/bash"
-	"github.com/fchastanet/bash-compiler/internal/utils/files"
 	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+	"github.com/fchastanet/bash-compiler/internal/utils/structures"
 )
 
+type noTemplateFileError struct {
+	error
+	TemplateDirs []string
+}
+
+func (e *noTemplateFileError) Error() string {
+	return fmt.Sprintf("no template file found in templateDirs %v", e.TemplateDirs)
+}
+
 type TemplateContext struct{}
 
 type templateInterface interface {
@@ -38,6 +49,10 @@ type TemplateContextData struct {
 	Template        templateInterface
 	RootData        any
 	Data            any
+	// IncludedFiles collects the files included as template during rendering
+	IncludedFiles map[string]bool
+	// SourceMapMarkers adds the source map markers around the files included as template
+	SourceMapMarkers bool
 }
 
 func NewTemplateContext() (templateContext *TemplateContext) {
@@ -61,16 +76,25 @@ func (templateContext *TemplateContext) Init(
 	}
 
 	templateContextData := &TemplateContextData{
-		TemplateContext: templateContext,
-		TemplateName:    &templateName,
-		Template:        myTemplate,
-		RootData:        data,
-		Data:            data,
+		TemplateContext:  templateContext,
+		TemplateName:     &templateName,
+		Template:         myTemplate,
+		RootData:         data,
+		Data:             data,
+		IncludedFiles:    make(map[string]bool),
+		SourceMapMarkers: false,
 	}
 
 	return templateContextData, nil
 }
 
+// GetIncludedFiles returns the sorted list of files included as template
+func (templateContextData *TemplateContextData) GetIncludedFiles() []string {
+	includedFiles := structures.MapKeys(templateContextData.IncludedFiles)
+	sort.Strings(includedFiles)
+	return includedFiles
+}
+
 func (*TemplateContext) Render(
 	templateContextData *TemplateContextData,
 	templateName string,
@@ -117,28 +141,37 @@ func newTemplate(
 	templateFile string,
 	funcMap template.FuncMap,
 ) (templateInstance *template.Template, templateName string, err error) {
+	templateBaseFile := path.Base(templateFile)
+	templateName = strings.TrimSuffix(templateBaseFile, filepath.Ext(templateBaseFile))
+	myTemplate := template.New(templateName).Option("missingkey=zero").Funcs(funcMap)
+
 	var filesList []string
 	for _, templateDir := range templateDirs {
-		myFiles, err := files.MatchPatterns(templateDir, "*.gtpl", "**/*.gtpl")
+		templateDirFS := TemplateDirFS(templateDir)
+		myFiles, err := matchTemplateFiles(templateDirFS)
 		if err != nil {
 			return nil, "", err
 		}
-		filesList = append(filesList, myFiles...)
+		if len(myFiles) == 0 {
+			continue
+		}
+		// templates of the following directories override the previous ones
+		_, err = myTemplate.ParseFS(templateDirFS, myFiles...)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, myFile := range myFiles {
+			filesList = append(filesList, filepath.Join(templateDir, myFile))
+		}
 	}
-
-	templateBaseFile := path.Base(templateFile)
-	templateName = strings.TrimSuffix(templateBaseFile, filepath.Ext(templateBaseFile))
 	slog.Debug(
 		"Loaded template",
 		logger.LogFieldTemplateDirs, templateDirs,
 		logger.LogFieldTemplateName, templateName,
 		logger.LogFieldAvailableTemplateFiles, filesList,
 	)
-
-	myTemplate := template.New(templateName).Option("missingkey=zero").Funcs(funcMap)
-	_, err = myTemplate.ParseFiles(filesList...)
-	if err != nil {
-		return nil, "", err
+	if len(filesList) == 0 {
+		return nil, "", &noTemplateFileError{nil, templateDirs}
 	}
 
 	return myTemplate, templateName, nil
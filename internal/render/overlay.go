@@ -0,0 +1,124 @@
+package render
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/fchastanet/bash-compiler/internal/utils/files"
+)
+
+// mountedTemplateDirs are template directories served from a fs.FS instead
+// of the disk, eg: the default templates embedded in the binary
+var (
+	mountedTemplateDirs      = map[string]fs.FS{}
+	mountedTemplateDirsMutex sync.RWMutex
+)
+
+// MountTemplateDir allows to use dir in templateDirs, its templates being read from fsys
+func MountTemplateDir(dir string, fsys fs.FS) {
+	mountedTemplateDirsMutex.Lock()
+	defer mountedTemplateDirsMutex.Unlock()
+	mountedTemplateDirs[filepath.Clean(dir)] = fsys
+}
+
+// UnmountTemplateDir removes a directory previously mounted using MountTemplateDir
+func UnmountTemplateDir(dir string) {
+	mountedTemplateDirsMutex.Lock()
+	defer mountedTemplateDirsMutex.Unlock()
+	delete(mountedTemplateDirs, filepath.Clean(dir))
+}
+
+// IsMountedTemplateDir indicates if dir is served from a fs.FS
+func IsMountedTemplateDir(dir string) bool {
+	_, mounted := getMountedTemplateDir(dir)
+	return mounted
+}
+
+func getMountedTemplateDir(dir string) (fs.FS, bool) {
+	mountedTemplateDirsMutex.RLock()
+	defer mountedTemplateDirsMutex.RUnlock()
+	fsys, mounted := mountedTemplateDirs[filepath.Clean(dir)]
+	return fsys, mounted
+}
+
+// TemplateDirExists checks that dir is a mounted template directory or an existing directory
+func TemplateDirExists(dir string) error {
+	if IsMountedTemplateDir(dir) {
+		return nil
+	}
+	return files.DirExists(dir)
+}
+
+// getMountedFile returns the file system of the mounted template directory containing filePath
+// and the path of the file relative to it
+func getMountedFile(filePath string) (fsys fs.FS, relativePath string, mounted bool) {
+	filePath = filepath.Clean(filePath)
+	mountedTemplateDirsMutex.RLock()
+	defer mountedTemplateDirsMutex.RUnlock()
+	for dir, fsys := range mountedTemplateDirs {
+		relativePath, found := strings.CutPrefix(filePath, dir+string(filepath.Separator))
+		if found {
+			return fsys, filepath.ToSlash(relativePath), true
+		}
+	}
+	return nil, "", false
+}
+
+// readFile reads the file from the mounted template directory containing it if any,
+// from the disk otherwise
+func readFile(filePath string) ([]byte, error) {
+	if fsys, relativePath, mounted := getMountedFile(filePath); mounted {
+		return fs.ReadFile(fsys, relativePath)
+	}
+	return os.ReadFile(filepath.Clean(filePath))
+}
+
+type mountedFileWasExpectedError struct {
+	error
+	FilePath string
+}
+
+func (e *mountedFileWasExpectedError) Error() string {
+	return "file was expected in mounted template directory: " + e.FilePath
+}
+
+// fileExists checks that the file exists in the mounted template directory containing it if any,
+// on the disk otherwise
+func fileExists(filePath string) error {
+	fsys, relativePath, mounted := getMountedFile(filePath)
+	if !mounted {
+		return files.FileExists(filePath)
+	}
+	stat, err := fs.Stat(fsys, relativePath)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return &mountedFileWasExpectedError{nil, filePath}
+	}
+	return nil
+}
+
+// TemplateDirFS returns the file system serving the templates of the directory
+func TemplateDirFS(dir string) fs.FS {
+	if fsys, mounted := getMountedTemplateDir(dir); mounted {
+		return fsys
+	}
+	return os.DirFS(dir)
+}
+
+// matchTemplateFiles returns the templates of the directory relative to its file system
+func matchTemplateFiles(fsys fs.FS) ([]string, error) {
+	// skipcq: GO-S1047 // skipped as FilesOnly option used
+	return doublestar.Glob(
+		fsys,
+		"**/*.gtpl",
+		doublestar.WithFailOnIOErrors(),
+		doublestar.WithFilesOnly(),
+		doublestar.WithNoFollow(),
+	)
+}
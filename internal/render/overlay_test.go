@@ -0,0 +1,82 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"gotest.tools/v3/assert"
+)
+
+const mountedDir = "/virtual/defaultTemplates"
+
+func mountTestTemplateDir(t *testing.T) {
+	t.Helper()
+	MountTemplateDir(mountedDir, fstest.MapFS{
+		"main.gtpl":        {Data: []byte(`main {{ template "sub" . }}`)},
+		"sub/sub.gtpl":     {Data: []byte(`{{ define "sub" }}embedded sub{{ end }}`)},
+		"sub/include.file": {Data: []byte("included content")},
+	})
+	t.Cleanup(func() {
+		UnmountTemplateDir(mountedDir)
+	})
+}
+
+func TestNewTemplateFromMountedDir(t *testing.T) {
+	mountTestTemplateDir(t)
+	myTemplate, templateName, err := newTemplate([]string{mountedDir}, "main.gtpl", FuncMap())
+	assert.NilError(t, err)
+	assert.Equal(t, "main", templateName)
+	var output strings.Builder
+	err = myTemplate.ExecuteTemplate(&output, "main.gtpl", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, "main embedded sub", output.String())
+}
+
+func TestNewTemplateOverridesMountedDir(t *testing.T) {
+	mountTestTemplateDir(t)
+	overrideDir := t.TempDir()
+	err := os.WriteFile(
+		filepath.Join(overrideDir, "sub.gtpl"), []byte(`{{ define "sub" }}overridden sub{{ end }}`), 0o600,
+	)
+	assert.NilError(t, err)
+	myTemplate, _, err := newTemplate([]string{mountedDir, overrideDir}, "main.gtpl", FuncMap())
+	assert.NilError(t, err)
+	var output strings.Builder
+	err = myTemplate.ExecuteTemplate(&output, "main.gtpl", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, "main overridden sub", output.String())
+}
+
+func TestNewTemplateWithoutTemplateFile(t *testing.T) {
+	_, _, err := newTemplate([]string{t.TempDir()}, "main.gtpl", FuncMap())
+	assert.ErrorContains(t, err, "no template file found in templateDirs")
+}
+
+func TestReadFileFromMountedDir(t *testing.T) {
+	mountTestTemplateDir(t)
+	content, err := readFile(mountedDir + "/sub/include.file")
+	assert.NilError(t, err)
+	assert.Equal(t, "included content", string(content))
+	assert.NilError(t, TemplateDirExists(mountedDir))
+	assert.ErrorContains(t, TemplateDirExists("/virtual/notMounted"), "")
+}
+
+func TestDynamicFileFromMountedDir(t *testing.T) {
+	mountTestTemplateDir(t)
+	t.Setenv("MOUNTED_DIR", mountedDir)
+	otherDir := t.TempDir()
+	assert.Equal(t, mountedDir+"/sub/include.file", dynamicFile("sub/include.file", []string{otherDir, "${MOUNTED_DIR}"}))
+	assert.Equal(t, mountedDir+"/sub/include.file", dynamicFile(mountedDir+"/sub/include.file", nil))
+	assert.NilError(t, fileExists(mountedDir+"/main.gtpl"))
+	assert.Error(t, fileExists(mountedDir+"/sub"),
+		"file was expected in mounted template directory: "+mountedDir+"/sub")
+	assert.ErrorContains(t, fileExists(mountedDir+"/missing.file"), "file does not exist")
+
+	// disk files take precedence in the order of the paths
+	err := os.WriteFile(filepath.Join(otherDir, "main.gtpl"), []byte("disk"), 0o600)
+	assert.NilError(t, err)
+	assert.Equal(t, filepath.Join(otherDir, "main.gtpl"), dynamicFile("main.gtpl", []string{otherDir, mountedDir}))
+}
@@ -0,0 +1,93 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SourceMapKindFunction code of a function file inserted by the compiler
+	SourceMapKindFunction = "function"
+	// SourceMapKindIncludedFile file included using includeFileAsTemplate (eg: main file)
+	SourceMapKindIncludedFile = "includedFile"
+
+	sourceMapEndMarker = "# @sourceMap end"
+	sourceMapNoName    = "-"
+)
+
+var sourceMapBeginMarkerRegexp = regexp.MustCompile(
+	`^# @sourceMap begin (?P<kind>[a-zA-Z]+) (?P<srcLine>[0-9]+) (?P<name>[^ ]+) (?P<srcFile>.+)$`,
+)
+
+// SourceMapMarker describes the origin of the lines following a begin marker
+type SourceMapMarker struct {
+	Kind    string
+	Name    string
+	SrcFile string
+	// SrcLine is the line of SrcFile matching the first line following the marker
+	SrcLine int
+}
+
+// AddSourceMapMarkers surrounds the code with comment lines indicating its origin,
+// these markers are removed by the compiler once the source map is computed.
+// Leading blank lines (and shebang) and trailing blank lines are kept outside of
+// the markers so that trimming the code gives the same result once markers are removed.
+func AddSourceMapMarkers(code string, kind string, name string, srcFile string) string {
+	if srcFile == "" || code == "" {
+		return code
+	}
+	if name == "" {
+		name = sourceMapNoName
+	}
+	lines := strings.SplitAfter(code, "\n")
+	firstLine := 0
+	for firstLine < len(lines) {
+		line := strings.TrimSpace(lines[firstLine])
+		if line != "" && !(firstLine == 0 && strings.HasPrefix(line, "#!")) {
+			break
+		}
+		firstLine++
+	}
+	if firstLine == len(lines) {
+		return code
+	}
+	leading := strings.Join(lines[:firstLine], "")
+	body := strings.Join(lines[firstLine:], "")
+	trimmedBody := strings.TrimRight(body, " \t\r\n")
+	trailing := body[len(trimmedBody):]
+	if trailing == "" {
+		trailing = "\n"
+	}
+	return fmt.Sprintf(
+		"%s# @sourceMap begin %s %d %s %s\n%s\n%s%s",
+		leading, kind, firstLine+1, name, srcFile, trimmedBody, sourceMapEndMarker, trailing,
+	)
+}
+
+// ParseSourceMapMarker indicates if the line is a marker added by AddSourceMapMarkers,
+// the marker is returned for begin markers, nil for end markers
+func ParseSourceMapMarker(line string) (isMarker bool, marker *SourceMapMarker) {
+	if line == sourceMapEndMarker {
+		return true, nil
+	}
+	matches := sourceMapBeginMarkerRegexp.FindStringSubmatch(line)
+	if matches == nil {
+		return false, nil
+	}
+	srcLine, err := strconv.Atoi(matches[sourceMapBeginMarkerRegexp.SubexpIndex("srcLine")])
+	if err != nil {
+		return false, nil
+	}
+	marker = &SourceMapMarker{
+		Kind:    matches[sourceMapBeginMarkerRegexp.SubexpIndex("kind")],
+		Name:    matches[sourceMapBeginMarkerRegexp.SubexpIndex("name")],
+		SrcFile: matches[sourceMapBeginMarkerRegexp.SubexpIndex("srcFile")],
+		SrcLine: srcLine,
+	}
+	if marker.Name == sourceMapNoName {
+		marker.Name = ""
+	}
+	return true, marker
+}
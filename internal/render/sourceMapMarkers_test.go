@@ -0,0 +1,57 @@
+package render
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAddSourceMapMarkers(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{
+			name:     "empty code",
+			code:     "",
+			expected: "",
+		},
+		{
+			name:     "blank code",
+			code:     "\n\n",
+			expected: "\n\n",
+		},
+		{
+			name: "shebang and blank lines kept outside of the markers",
+			code: "#!/usr/bin/env bash\n\necho 1\necho 2\n\n",
+			expected: "#!/usr/bin/env bash\n\n# @sourceMap begin includedFile 3 - /src/main.sh\n" +
+				"echo 1\necho 2\n# @sourceMap end\n\n",
+		},
+		{
+			name:     "missing trailing newline",
+			code:     "echo 1",
+			expected: "# @sourceMap begin includedFile 1 - /src/main.sh\necho 1\n# @sourceMap end\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, AddSourceMapMarkers(tt.code, SourceMapKindIncludedFile, "", "/src/main.sh"))
+		})
+	}
+}
+
+func TestParseSourceMapMarker(t *testing.T) {
+	isMarker, marker := ParseSourceMapMarker("# @sourceMap begin function 3 Log::info /src/Log/info file.sh")
+	assert.Assert(t, isMarker)
+	assert.DeepEqual(t, &SourceMapMarker{
+		Kind: SourceMapKindFunction, Name: "Log::info", SrcFile: "/src/Log/info file.sh", SrcLine: 3,
+	}, marker)
+
+	isMarker, marker = ParseSourceMapMarker("# @sourceMap end")
+	assert.Assert(t, isMarker)
+	assert.Assert(t, marker == nil)
+
+	isMarker, _ = ParseSourceMapMarker("# @sourceMap other")
+	assert.Assert(t, !isMarker)
+}
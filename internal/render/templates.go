@@ -8,7 +8,6 @@ import (
 	"path"
 
 	"github.com/fchastanet/bash-compiler/internal/utils/bash"
-	"github.com/fchastanet/bash-compiler/internal/utils/files"
 	"github.com/fchastanet/bash-compiler/internal/utils/logger"
 )
 
@@ -60,7 +59,7 @@ func includeFile(filePath string) string {
 		logger.LogFieldFilePathExpanded, filePathExpanded,
 	)
 
-	file, err := os.ReadFile(filePathExpanded)
+	file, err := readFile(filePathExpanded)
 	logger.Check(err)
 
 	return string(file)
@@ -77,15 +76,23 @@ func includeFileAsTemplate(
 		logger.LogFieldFilePathExpanded, filePathExpanded,
 	)
 
-	fileContent, err := os.ReadFile(filePathExpanded)
+	fileContent, err := readFile(filePathExpanded)
 	logger.Check(err)
+	if templateContextData.IncludedFiles != nil {
+		templateContextData.IncludedFiles[filePathExpanded] = true
+	}
 
 	code, err := templateContextData.TemplateContext.RenderFromTemplateContent(
 		&templateContextData, string(fileContent))
 	logger.Check(err)
-	return code
+	if !templateContextData.SourceMapMarkers {
+		return code
+	}
+	return AddSourceMapMarkers(code, SourceMapKindIncludedFile, "", filePathExpanded)
 }
 
+// dynamicFile returns the first existing file among filePath and filePath relative to each path,
+// the mounted template directories being checked like the disk
 func dynamicFile(filePath string, paths []string) string {
 	filePathExpanded := os.ExpandEnv(filePath)
 	slog.Debug(
@@ -93,7 +100,7 @@ func dynamicFile(filePath string, paths []string) string {
 		logger.LogFieldFilePath, filePath,
 		logger.LogFieldFilePathExpanded, filePathExpanded,
 	)
-	err := files.FileExists(filePathExpanded)
+	err := fileExists(filePathExpanded)
 	if err == nil {
 		return filePathExpanded
 	}
@@ -107,7 +114,7 @@ func dynamicFile(filePath string, paths []string) string {
 			logger.LogFieldDirPathExpanded, dirExpanded,
 			logger.LogFieldFilePathExpanded, currentPath,
 		)
-		if err := files.FileExists(currentPath); err == nil {
+		if err := fileExists(currentPath); err == nil {
 			return currentPath
 		}
 	}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+// postCompileCommandEnvPrefix is the prefix of the variables of .bash-compiler
+// defining the commands run on every compiled binary (eg: POST_COMPILE_COMMAND_SHELLCHECK)
+const postCompileCommandEnvPrefix = "POST_COMPILE_COMMAND_"
+
+// PostCompileCommandResult is the outcome of a command run on the compiled binary
+type PostCompileCommandResult struct {
+	Command  string `json:"command"`
+	ExitCode int    `json:"exitCode"`
+	// Output is the combined stdout and stderr of the command
+	Output     string `json:"output"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type postCompileCommandsFailedError struct {
+	error
+	TargetFile string
+	Commands   []string
+}
+
+func (e *postCompileCommandsFailedError) Error() string {
+	return fmt.Sprintf("post compile commands failed on %s: %s", e.TargetFile, strings.Join(e.Commands, ", "))
+}
+
+// getGlobalPostCompileCommands returns the commands defined in .bash-compiler
+// sorted by variable name
+func getGlobalPostCompileCommands() []string {
+	variableNames := []string{}
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(name, postCompileCommandEnvPrefix) && strings.TrimSpace(value) != "" {
+			variableNames = append(variableNames, name)
+		}
+	}
+	sort.Strings(variableNames)
+	commands := make([]string, 0, len(variableNames))
+	for _, name := range variableNames {
+		commands = append(commands, os.Getenv(name))
+	}
+	return commands
+}
+
+// unsetGlobalPostCompileCommands avoids to run commands coming from
+// the environment instead of .bash-compiler
+func unsetGlobalPostCompileCommands() {
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(name, postCompileCommandEnvPrefix) {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+// runPostCompileCommands runs each command using bash with the target file
+// as first argument ($1) and as TARGET_FILE variable.
+// All the commands are run even if one of them fails.
+func runPostCompileCommands(commands []string, targetFile string) ([]PostCompileCommandResult, error) {
+	results := make([]PostCompileCommandResult, 0, len(commands))
+	failedCommands := []string{}
+	for _, command := range commands {
+		result := runPostCompileCommand(command, targetFile)
+		results = append(results, result)
+		if result.ExitCode != 0 {
+			slog.Error("Post compile command failed",
+				logger.LogFieldFilePath, targetFile,
+				"command", command, "exitCode", result.ExitCode, "output", result.Output,
+			)
+			failedCommands = append(failedCommands, command)
+			continue
+		}
+		slog.Info("Post compile command succeeded",
+			logger.LogFieldFilePath, targetFile, "command", command, "durationMs", result.DurationMs,
+		)
+	}
+	if len(failedCommands) > 0 {
+		return results, &postCompileCommandsFailedError{nil, targetFile, failedCommands}
+	}
+	return results, nil
+}
+
+func runPostCompileCommand(command string, targetFile string) PostCompileCommandResult {
+	startTime := time.Now()
+	cmd := exec.Command("bash", "-c", command, "bash", targetFile)
+	cmd.Env = append(os.Environ(), "TARGET_FILE="+targetFile)
+	output, err := cmd.CombinedOutput()
+	result := PostCompileCommandResult{
+		Command:    command,
+		ExitCode:   0,
+		Output:     string(output),
+		DurationMs: time.Since(startTime).Milliseconds(),
+	}
+	var exitError *exec.ExitError
+	switch {
+	case errors.As(err, &exitError):
+		result.ExitCode = exitError.ExitCode()
+	case err != nil:
+		// command could not be started
+		result.ExitCode = -1
+		result.Output += err.Error()
+	}
+	return result
+}
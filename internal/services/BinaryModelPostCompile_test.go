@@ -0,0 +1,48 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestGetGlobalPostCompileCommands(t *testing.T) {
+	t.Setenv("POST_COMPILE_COMMAND_SHFMT", "shfmt -d \"$1\"")
+	t.Setenv("POST_COMPILE_COMMAND_EMPTY", " ")
+	t.Setenv("POST_COMPILE_COMMAND_A_SHELLCHECK", "shellcheck \"$1\"")
+	assert.DeepEqual(t, []string{"shellcheck \"$1\"", "shfmt -d \"$1\""}, getGlobalPostCompileCommands())
+
+	unsetGlobalPostCompileCommands()
+	assert.DeepEqual(t, []string{}, getGlobalPostCompileCommands())
+}
+
+func TestRunPostCompileCommands(t *testing.T) {
+	targetFile := filepath.Join(t.TempDir(), "myBinary")
+	assert.NilError(t, os.WriteFile(targetFile, []byte("#!/bin/bash\necho hello\n"), 0o700))
+
+	t.Run("success", func(t *testing.T) {
+		results, err := runPostCompileCommands(
+			[]string{`wc -l < "$1"`, `test "$1" = "${TARGET_FILE}" && echo same`}, targetFile,
+		)
+		assert.NilError(t, err)
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, "2\n", results[0].Output)
+		assert.Equal(t, 0, results[0].ExitCode)
+		assert.Equal(t, "same\n", results[1].Output)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		results, err := runPostCompileCommands(
+			[]string{"echo failure >&2; exit 3", "echo next"}, targetFile,
+		)
+		assert.Error(t, err, "post compile commands failed on "+targetFile+": echo failure >&2; exit 3")
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, 3, results[0].ExitCode)
+		assert.Equal(t, "failure\n", results[0].Output)
+		// following commands are run anyway
+		assert.Equal(t, 0, results[1].ExitCode)
+		assert.Equal(t, "next\n", results[1].Output)
+	})
+}
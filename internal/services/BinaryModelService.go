@@ -1,9 +1,14 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 
 	"github.com/fchastanet/bash-compiler/internal/compiler"
 	"github.com/fchastanet/bash-compiler/internal/model"
@@ -20,6 +25,13 @@ type CodeCompilerInterface interface {
 		config *model.CompilerConfig,
 	) (*compiler.CompileContextData, error)
 	Compile(compileContextData *compiler.CompileContextData, code string) (codeCompiled string, err error)
+	CompileWithSourceMap(
+		compileContextData *compiler.CompileContextData, code string,
+	) (codeCompiled string, sourceMap *compiler.SourceMap, err error)
+	Analyze(
+		compileContextData *compiler.CompileContextData, code string, binary string,
+	) (*compiler.DependencyGraph, error)
+	Which(compileContextData *compiler.CompileContextData, functionName string) *compiler.FunctionResolution
 }
 
 type BinaryModelLoaderInterface interface {
@@ -69,6 +81,35 @@ type BinaryModelServiceContext struct {
 	) (err error)
 }
 
+// BinaryModelResult describes the outcome of a binary model compilation
+type BinaryModelResult struct {
+	BinaryModelFilePath string `json:"binaryModelFilePath"`
+	TargetFile          string `json:"targetFile"`
+	// Stale is true if the target file content differs from the compiled code
+	Stale bool `json:"stale"`
+	// Diff is the unified diff between the target file and the compiled code
+	Diff string `json:"diff,omitempty"`
+	// Functions are the functions included in the binary with their @require relations
+	Functions []compiler.IncludedFunction `json:"functions"`
+	// EmbeddedResources are the resources embedded using @embed annotation
+	EmbeddedResources []compiler.EmbeddedResource `json:"embeddedResources"`
+	// SizeReduction is the size of the code before and after the release stripping, nil if not in release mode
+	SizeReduction *compiler.SizeReduction `json:"sizeReduction,omitempty"`
+	// DeprecatedFunctions are the functions annotated with @deprecated included in the binary
+	DeprecatedFunctions []compiler.DeprecatedFunctionUsage `json:"deprecatedFunctions,omitempty"`
+	// PostCompileCommands are the results of the commands run on the target file once written
+	PostCompileCommands []PostCompileCommandResult `json:"postCompileCommands,omitempty"`
+	// DurationMs is the time spent to load and compile the binary model
+	DurationMs int64 `json:"durationMs"`
+	// Error is the compilation error message if any
+	Error string `json:"error,omitempty"`
+
+	targetFileExists bool
+	previousCode     string
+	code             string
+	sourceMap        *compiler.SourceMap
+}
+
 type BinaryModelServiceContextData struct {
 	binaryModelData      *model.BinaryModel
 	compileContextData   *compiler.CompileContextData
@@ -146,6 +187,7 @@ func (binaryModelServiceContext *BinaryModelServiceContext) Init(
 	if err != nil {
 		return nil, err
 	}
+	templateContextData.SourceMapMarkers = binaryModelData.CompilerConfig.IsSourceMapEnabled()
 	binaryModelServiceContextData.templateContextData = templateContextData
 
 	// init code compiler
@@ -180,7 +222,7 @@ func (*BinaryModelServiceContext) Validate(
 		}
 	}
 	for _, dir := range templateDirs {
-		err := files.DirExists(dir)
+		err := render.TemplateDirExists(dir)
 		if err != nil {
 			return &customerrors.ValidationError{
 				InnerError: err,
@@ -193,26 +235,178 @@ func (*BinaryModelServiceContext) Validate(
 	return nil
 }
 
+// Compile renders the binary model and saves it in its target file,
+// nothing is written if dryRun is true.
+// The post compile commands are then run on the target file.
+// Comments and blank lines are removed from the compiled code if release is true.
 func (binaryModelServiceContext *BinaryModelServiceContext) Compile(
 	binaryModelServiceContextData *BinaryModelServiceContextData,
-) error {
-	codeCompiled, err := binaryModelServiceContext.renderCode(binaryModelServiceContextData)
+	dryRun bool,
+	release bool,
+) (*BinaryModelResult, error) {
+	codeCompiled, sourceMap, err := binaryModelServiceContext.renderCode(binaryModelServiceContextData)
 	if logger.FancyHandleError(err) {
-		return err
+		return nil, err
 	}
 
-	// Save resulting file
 	targetFile := structures.ExpandStringValue(
 		binaryModelServiceContextData.binaryModelData.CompilerConfig.TargetFile,
 	)
+	var sizeReduction *compiler.SizeReduction
+	if release {
+		codeCompiled, sourceMap, sizeReduction, err = compiler.StripForRelease(codeCompiled, sourceMap)
+		if logger.FancyHandleError(err) {
+			return nil, err
+		}
+		slog.Info("Release size reduction",
+			logger.LogFieldFilePath, targetFile,
+			"linesBefore", sizeReduction.LinesBefore, "linesAfter", sizeReduction.LinesAfter,
+			"bytesBefore", sizeReduction.BytesBefore, "bytesAfter", sizeReduction.BytesAfter,
+			"reductionPercent", fmt.Sprintf("%.1f", sizeReduction.Percent()),
+		)
+	}
+	compileContextData := binaryModelServiceContextData.compileContextData
+	embeddedResources, err := compileContextData.GetEmbeddedResources()
+	if logger.FancyHandleError(err) {
+		return nil, err
+	}
+	result, err := newBinaryModelResult(
+		binaryModelServiceContextData.binaryModelFilePath, targetFile, codeCompiled,
+	)
+	if err != nil {
+		return nil, err
+	}
+	result.Functions = compileContextData.GetIncludedFunctions()
+	result.EmbeddedResources = embeddedResources
+	result.SizeReduction = sizeReduction
+	result.DeprecatedFunctions = compileContextData.GetDeprecatedFunctionUsages()
+	if binaryModelServiceContextData.binaryModelData.CompilerConfig.SourceMap {
+		sourceMap.File = filepath.Base(targetFile)
+		result.setSourceMap(sourceMap)
+	}
+	if dryRun {
+		slog.Info("Compiled (dry run)", logger.LogFieldFilePath, targetFile, "stale", result.Stale)
+		return result, nil
+	}
+
+	err = saveTargetFile(result)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Compiled", logger.LogFieldFilePath, targetFile)
+
+	postCompileCommands := slices.Concat(
+		getGlobalPostCompileCommands(),
+		binaryModelServiceContextData.binaryModelData.CompilerConfig.PostCompileCommands,
+	)
+	if len(postCompileCommands) > 0 {
+		// the result is returned with the error so that commands output can be reported
+		result.PostCompileCommands, err = runPostCompileCommands(postCompileCommands, targetFile)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// newBinaryModelResult compares the code with the current content of the target file
+func newBinaryModelResult(binaryModelFilePath string, targetFile string, code string) (*BinaryModelResult, error) {
+	previousCode, err := os.ReadFile(targetFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.FancyHandleError(err)
+		return nil, err
+	}
+	return &BinaryModelResult{
+		BinaryModelFilePath: binaryModelFilePath,
+		TargetFile:          targetFile,
+		Stale:               err != nil || string(previousCode) != code,
+		Diff:                "",
+		Functions:           []compiler.IncludedFunction{},
+		EmbeddedResources:   []compiler.EmbeddedResource{},
+		SizeReduction:       nil,
+		DeprecatedFunctions: nil,
+		PostCompileCommands: nil,
+		DurationMs:          0,
+		Error:               "",
+		targetFileExists:    err == nil,
+		previousCode:        string(previousCode),
+		code:                code,
+		sourceMap:           nil,
+	}, nil
+}
 
-	err = os.WriteFile(targetFile, []byte(codeCompiled), files.UserReadWriteExecutePerm)
+// setSourceMap attaches the source map to write beside the target file,
+// the target file is considered stale if its source map is missing or outdated
+func (result *BinaryModelResult) setSourceMap(sourceMap *compiler.SourceMap) {
+	result.sourceMap = sourceMap
+	if !isSourceMapUpToDate(result) {
+		result.Stale = true
+	}
+}
+
+// isSourceMapUpToDate checks that the source map beside the target file
+// is the one attached to the result
+func isSourceMapUpToDate(result *BinaryModelResult) bool {
+	if result.sourceMap == nil {
+		return true
+	}
+	sourceMap, err := compiler.LoadSourceMap(result.TargetFile)
+	return err == nil && reflect.DeepEqual(sourceMap, result.sourceMap)
+}
+
+// saveTargetFile writes the compiled code and its source map beside it if any
+func saveTargetFile(result *BinaryModelResult) error {
+	err := os.WriteFile(result.TargetFile, []byte(result.code), files.UserReadWriteExecutePerm)
 	if logger.FancyHandleError(err) {
 		return err
 	}
-	slog.Info("Compiled", logger.LogFieldFilePath, targetFile)
+	if result.sourceMap == nil {
+		return nil
+	}
+	sourceMapContent, err := json.Marshal(result.sourceMap)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(result.TargetFile+compiler.SourceMapFileSuffix, sourceMapContent, files.AllReadPerm)
+	logger.FancyHandleError(err)
+	return err
+}
 
-	return nil
+// Analyze computes the dependency graph of the functions included in the binary
+func (binaryModelServiceContext *BinaryModelServiceContext) Analyze(
+	binaryModelServiceContextData *BinaryModelServiceContextData,
+) (*compiler.DependencyGraph, error) {
+	code, err := binaryModelServiceContext.renderBinaryCodeFromTemplate(binaryModelServiceContextData)
+	if logger.FancyHandleError(err) {
+		return nil, err
+	}
+	targetFile := structures.ExpandStringValue(
+		binaryModelServiceContextData.binaryModelData.CompilerConfig.TargetFile,
+	)
+	graph, err := binaryModelServiceContext.codeCompiler.Analyze(
+		binaryModelServiceContextData.compileContextData,
+		code,
+		filepath.Base(targetFile),
+	)
+	if err != nil {
+		return nil, err
+	}
+	graph.BinaryModelFilePath = binaryModelServiceContextData.binaryModelFilePath
+	return graph, nil
+}
+
+// Which resolves the source file of the function using the srcDirs of the binary model
+func (binaryModelServiceContext *BinaryModelServiceContext) Which(
+	binaryModelServiceContextData *BinaryModelServiceContextData,
+	functionName string,
+) *compiler.FunctionResolution {
+	resolution := binaryModelServiceContext.codeCompiler.Which(
+		binaryModelServiceContextData.compileContextData,
+		functionName,
+	)
+	resolution.BinaryModelFilePath = binaryModelServiceContextData.binaryModelFilePath
+	return resolution
 }
 
 func (binaryModelServiceContext *BinaryModelServiceContext) renderBinaryCodeFromTemplate(
@@ -241,14 +435,14 @@ func (binaryModelServiceContext *BinaryModelServiceContext) renderBinaryCodeFrom
 
 func (binaryModelServiceContext *BinaryModelServiceContext) renderCode(
 	binaryModelServiceContextData *BinaryModelServiceContextData,
-) (codeCompiled string, err error) {
+) (codeCompiled string, sourceMap *compiler.SourceMap, err error) {
 	code, err := binaryModelServiceContext.renderBinaryCodeFromTemplate(binaryModelServiceContextData)
 	if logger.FancyHandleError(err) {
-		return "", err
+		return "", nil, err
 	}
 
 	// Compile to get functions loaded once
-	return binaryModelServiceContext.codeCompiler.Compile(
+	return binaryModelServiceContext.codeCompiler.CompileWithSourceMap(
 		binaryModelServiceContextData.compileContextData,
 		code,
 	)
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fchastanet/bash-compiler/internal/compiler"
+	"github.com/fchastanet/bash-compiler/internal/model"
+	"github.com/fchastanet/bash-compiler/internal/render"
+	"github.com/fchastanet/bash-compiler/internal/utils/files"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+const (
+	buildCacheEntriesDir = "entries"
+	buildCacheObjectsDir = "objects"
+)
+
+// BuildCache allows to skip the compilation of a binary model when none of its
+// inputs changed since its last compilation.
+// Each compilation records the inputs of the binary model (merged model with the
+// environment variables expanded, function source files, templates, embedded
+// resources, compiler version and KCL schema) in an entry, the compiled code is
+// stored under the hash of these inputs.
+type BuildCache struct {
+	cacheDir        string
+	compilerVersion string
+	release         bool
+}
+
+// buildCacheEntry describes the inputs of the last compilation of a binary model
+type buildCacheEntry struct {
+	CompilerVersion     string `json:"compilerVersion"`
+	Release             bool   `json:"release"`
+	BinaryModelFilePath string `json:"binaryModelFilePath"`
+	TargetFile          string `json:"targetFile"`
+	// InputsHash is the hash of all the inputs, the compiled code is stored under this hash
+	InputsHash string `json:"inputsHash"`
+	// Files are the yaml files, the function source files and the templates included
+	Files []string `json:"files"`
+	// SrcDirs are the directories in which the functions are resolved,
+	// adding a function file in it could change the resolution
+	SrcDirs []string `json:"srcDirs"`
+	// TemplateDirs are the directories of the templates, they are all loaded
+	TemplateDirs      []string                    `json:"templateDirs"`
+	Functions         []compiler.IncludedFunction `json:"functions"`
+	EmbeddedResources []compiler.EmbeddedResource `json:"embeddedResources"`
+	SizeReduction     *compiler.SizeReduction     `json:"sizeReduction,omitempty"`
+	// DeprecatedFunctions are kept so that the warnings are reported again when the compilation is skipped
+	DeprecatedFunctions []compiler.DeprecatedFunctionUsage `json:"deprecatedFunctions,omitempty"`
+	// PostCompileCommands are run again when the target file is restored from the cache
+	PostCompileCommands []string `json:"postCompileCommands,omitempty"`
+}
+
+// NewBuildCache creates the cache stored in cacheDir,
+// compilerVersion invalidates the entries created by other versions,
+// release invalidates the entries created using the other output mode
+func NewBuildCache(cacheDir string, compilerVersion string, release bool) *BuildCache {
+	return &BuildCache{
+		cacheDir:        cacheDir,
+		compilerVersion: compilerVersion,
+		release:         release,
+	}
+}
+
+func (cache *BuildCache) getEntryFilePath(binaryModelFilePath string) string {
+	checksum := sha256.Sum256([]byte(filepath.Clean(binaryModelFilePath)))
+	return filepath.Join(cache.cacheDir, buildCacheEntriesDir, hex.EncodeToString(checksum[:])+".json")
+}
+
+func (cache *BuildCache) getObjectFilePath(inputsHash string) string {
+	return filepath.Join(cache.cacheDir, buildCacheObjectsDir, inputsHash)
+}
+
+// Lookup returns the result of the binary model compilation if its inputs did not change
+// since it has been stored, nil otherwise.
+// The target file is restored from the cache if needed, unless dryRun is true, the post
+// compile commands are then run again on it. They are not run if the target file is up to
+// date as a compilation is stored only if its post compile commands succeeded.
+// An error is returned only if the post compile commands fail, the entry is then removed
+// so that the next compilation reports the failure again.
+func (cache *BuildCache) Lookup(
+	binaryModelFilePath string, dryRun bool,
+) (*BinaryModelResult, *binaryModelDependencies, error) {
+	entry, err := cache.loadEntry(binaryModelFilePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn(
+				"Ignoring invalid build cache entry",
+				logger.LogFieldFilePath, binaryModelFilePath, logger.LogFieldErr, err,
+			)
+		}
+		return nil, nil, nil
+	}
+	if entry.CompilerVersion != cache.compilerVersion || entry.Release != cache.release {
+		return nil, nil, nil
+	}
+	inputsHash, err := computeInputsHash(entry)
+	if err != nil || inputsHash != entry.InputsHash {
+		slog.Debug("Build cache miss", logger.LogFieldFilePath, binaryModelFilePath, logger.LogFieldErr, err)
+		return nil, nil, nil
+	}
+	code, err := os.ReadFile(cache.getObjectFilePath(entry.InputsHash))
+	if err != nil {
+		return nil, nil, nil
+	}
+	result, err := newBinaryModelResult(binaryModelFilePath, entry.TargetFile, string(code))
+	if err != nil {
+		return nil, nil, nil
+	}
+	result.Functions = entry.Functions
+	result.EmbeddedResources = entry.EmbeddedResources
+	result.SizeReduction = entry.SizeReduction
+	result.DeprecatedFunctions = entry.DeprecatedFunctions
+	result.setSourceMap(cache.loadSourceMap(entry.InputsHash))
+	if dryRun || !result.Stale {
+		slog.Info("Up to date", logger.LogFieldFilePath, entry.TargetFile, "stale", result.Stale)
+		return result, entry.getDependencies(), nil
+	}
+	err = saveTargetFile(result)
+	if err != nil {
+		return nil, nil, nil
+	}
+	slog.Info("Restored from build cache", logger.LogFieldFilePath, entry.TargetFile)
+	if len(entry.PostCompileCommands) > 0 {
+		result.PostCompileCommands, err = runPostCompileCommands(entry.PostCompileCommands, entry.TargetFile)
+		if err != nil {
+			if removeErr := os.Remove(cache.getEntryFilePath(binaryModelFilePath)); removeErr != nil {
+				slog.Warn("Unable to remove build cache entry", logger.LogFieldErr, removeErr)
+			}
+			return result, entry.getDependencies(), err
+		}
+	}
+	return result, entry.getDependencies(), nil
+}
+
+// Store records the inputs of the compilation and the compiled code
+func (cache *BuildCache) Store(result *BinaryModelResult, dependencies *binaryModelDependencies) error {
+	entry := &buildCacheEntry{
+		CompilerVersion:     cache.compilerVersion,
+		Release:             cache.release,
+		BinaryModelFilePath: result.BinaryModelFilePath,
+		TargetFile:          result.TargetFile,
+		InputsHash:          "",
+		Files:               dependencies.getSortedFiles(),
+		SrcDirs:             dependencies.srcDirs,
+		TemplateDirs:        dependencies.templateDirs,
+		Functions:           result.Functions,
+		EmbeddedResources:   result.EmbeddedResources,
+		SizeReduction:       result.SizeReduction,
+		DeprecatedFunctions: result.DeprecatedFunctions,
+		PostCompileCommands: nil,
+	}
+	for _, postCompileCommand := range result.PostCompileCommands {
+		entry.PostCompileCommands = append(entry.PostCompileCommands, postCompileCommand.Command)
+	}
+	var err error
+	entry.InputsHash, err = computeInputsHash(entry)
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomically(cache.getObjectFilePath(entry.InputsHash), []byte(result.code))
+	if err != nil {
+		return err
+	}
+	if result.sourceMap != nil {
+		sourceMapContent, err := json.Marshal(result.sourceMap)
+		if err != nil {
+			return err
+		}
+		err = writeFileAtomically(cache.getObjectFilePath(entry.InputsHash)+compiler.SourceMapFileSuffix, sourceMapContent)
+		if err != nil {
+			return err
+		}
+	}
+	content, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(cache.getEntryFilePath(result.BinaryModelFilePath), content)
+}
+
+// loadSourceMap returns the source map stored with the compiled code,
+// nil if not available (eg: compilerConfig.sourceMap disabled)
+func (cache *BuildCache) loadSourceMap(inputsHash string) *compiler.SourceMap {
+	sourceMap, err := compiler.LoadSourceMap(cache.getObjectFilePath(inputsHash))
+	if err != nil {
+		return nil
+	}
+	return sourceMap
+}
+
+func (cache *BuildCache) loadEntry(binaryModelFilePath string) (*buildCacheEntry, error) {
+	content, err := os.ReadFile(cache.getEntryFilePath(binaryModelFilePath))
+	if err != nil {
+		return nil, err
+	}
+	entry := &buildCacheEntry{} //nolint:exhaustruct // loaded from json
+	err = json.Unmarshal(content, entry)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (entry *buildCacheEntry) getDependencies() *binaryModelDependencies {
+	dependencies := &binaryModelDependencies{
+		targetFile:   filepath.Clean(entry.TargetFile),
+		files:        map[string]bool{},
+		srcDirs:      entry.SrcDirs,
+		templateDirs: entry.TemplateDirs,
+	}
+	dependencies.addFiles(entry.Files)
+	return dependencies
+}
+
+func (dependencies *binaryModelDependencies) getSortedFiles() []string {
+	sortedFiles := make([]string, 0, len(dependencies.files))
+	for file := range dependencies.files {
+		sortedFiles = append(sortedFiles, file)
+	}
+	sort.Strings(sortedFiles)
+	return sortedFiles
+}
+
+// computeInputsHash computes the hash of the current content of the inputs of the entry.
+// The binary model is merged and expanded using the current environment so that a change
+// of a variable used by the model is detected.
+// Only the names of the files of srcDirs are hashed, the resolution of a function being
+// impacted by the files added or removed, the content of the included functions files is
+// hashed using the functions of the entry.
+func computeInputsHash(entry *buildCacheEntry) (string, error) {
+	expandedModel, err := model.NewBinaryModelLoader().LoadExpandedModel(
+		entry.BinaryModelFilePath, filepath.Dir(entry.BinaryModelFilePath),
+	)
+	if err != nil {
+		return "", err
+	}
+	inputsHash := sha256.New()
+	fmt.Fprintf(
+		inputsHash, "version %s\nschema %s\nrelease %t\ntarget %s\nmodel %d\n%s\n",
+		entry.CompilerVersion, model.SchemaChecksum(), entry.Release, entry.TargetFile,
+		len(expandedModel), expandedModel,
+	)
+	for _, file := range entry.Files {
+		checksum, err := files.ChecksumFromFile(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(inputsHash, "file %s %s\n", file, checksum)
+	}
+	for _, function := range entry.Functions {
+		if function.SrcFile == "" {
+			continue
+		}
+		checksum, err := files.ChecksumFromFile(function.SrcFile)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(inputsHash, "function %s %s %s\n", function.FunctionName, function.SrcFile, checksum)
+	}
+	for _, srcDir := range entry.SrcDirs {
+		fmt.Fprintf(inputsHash, "srcDir %s\n", srcDir)
+		err := hashDirectory(inputsHash, os.DirFS(srcDir), ".sh", false)
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, templateDir := range entry.TemplateDirs {
+		fmt.Fprintf(inputsHash, "templateDir %s\n", templateDir)
+		err := hashDirectory(inputsHash, render.TemplateDirFS(templateDir), ".gtpl", true)
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, embeddedResource := range entry.EmbeddedResources {
+		checksum, err := compiler.EmbeddedResourceChecksum(embeddedResource.Resource)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(inputsHash, "embed %s %s %s\n", embeddedResource.AsName, embeddedResource.Resource, checksum)
+	}
+	return hex.EncodeToString(inputsHash.Sum(nil)), nil
+}
+
+// hashDirectory adds to the hash the list of the files having the given extension,
+// and their content if withContent is true
+func hashDirectory(inputsHash hash.Hash, fsys fs.FS, extension string, withContent bool) error {
+	return fs.WalkDir(fsys, ".", func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil || dirEntry.IsDir() || !strings.HasSuffix(path, extension) {
+			return err
+		}
+		fmt.Fprintf(inputsHash, "%s\n", path)
+		if !withContent {
+			return nil
+		}
+		file, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(inputsHash, file)
+		return err
+	})
+}
+
+// writeFileAtomically avoids to leave a partial file if the process is interrupted,
+// and allows several workers to write the same object
+func writeFileAtomically(filePath string, content []byte) error {
+	err := os.MkdirAll(filepath.Dir(filePath), files.AllReadExecutePerm)
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(content)
+	if err != nil {
+		tempFile.Close()
+		return err
+	}
+	err = tempFile.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), filePath)
+}
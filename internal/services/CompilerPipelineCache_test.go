@@ -0,0 +1,266 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/compiler"
+	"gotest.tools/v3/assert"
+)
+
+// buildCacheTestBinaryModel uses a variable that is expanded when computing the inputs hash
+const buildCacheTestBinaryModel = "binData: {}\ncompilerConfig:\n  srcDirs: [\"${CACHE_TEST_DIR}/src\"]\n"
+
+type buildCacheTestProject struct {
+	binaryModelFile string
+	functionFile    string
+	srcDir          string
+	templateDir     string
+	targetFile      string
+}
+
+func newBuildCacheTestProject(t *testing.T) *buildCacheTestProject {
+	t.Helper()
+	rootDir := t.TempDir()
+	t.Setenv("CACHE_TEST_DIR", rootDir)
+	project := &buildCacheTestProject{
+		binaryModelFile: filepath.Join(rootDir, "bin1-binary.yaml"),
+		functionFile:    filepath.Join(rootDir, "src", "Log", "info.sh"),
+		srcDir:          filepath.Join(rootDir, "src"),
+		templateDir:     filepath.Join(rootDir, "templates"),
+		targetFile:      filepath.Join(rootDir, "bin1"),
+	}
+	for filePath, content := range map[string]string{
+		project.binaryModelFile:                            buildCacheTestBinaryModel,
+		project.functionFile:                               "Log::info() { :; }",
+		filepath.Join(project.templateDir, "binFile.gtpl"): "{{ .binData }}",
+		project.targetFile:                                 "compiled code",
+	} {
+		assert.NilError(t, os.MkdirAll(filepath.Dir(filePath), 0o700))
+		assert.NilError(t, os.WriteFile(filePath, []byte(content), 0o600))
+	}
+	return project
+}
+
+func (project *buildCacheTestProject) store(t *testing.T, cache *BuildCache) {
+	t.Helper()
+	result, err := newBinaryModelResult(project.binaryModelFile, project.targetFile, "compiled code")
+	assert.NilError(t, err)
+	result.Functions = []compiler.IncludedFunction{
+		{FunctionName: "Log::info", SrcFile: project.functionFile, InsertPosition: compiler.InsertPositionMiddle},
+	}
+	dependencies := newBinaryModelDependencies(project.binaryModelFile, nil)
+	dependencies.targetFile = project.targetFile
+	dependencies.addFiles([]string{project.functionFile})
+	dependencies.srcDirs = []string{project.srcDir}
+	dependencies.templateDirs = []string{project.templateDir}
+	assert.NilError(t, cache.Store(result, dependencies))
+}
+
+func TestBuildCacheHit(t *testing.T) {
+	project := newBuildCacheTestProject(t)
+	cache := NewBuildCache(t.TempDir(), "1.0.0", false)
+	project.store(t, cache)
+
+	result, dependencies, err := cache.Lookup(project.binaryModelFile, false)
+	assert.NilError(t, err)
+	assert.Assert(t, result != nil)
+	assert.Equal(t, false, result.Stale)
+	assert.Equal(t, "Log::info", result.Functions[0].FunctionName)
+	assert.Assert(t, dependencies.isImpactedBy(project.functionFile, false))
+}
+
+func TestBuildCacheRestoresTargetFile(t *testing.T) {
+	project := newBuildCacheTestProject(t)
+	cache := NewBuildCache(t.TempDir(), "1.0.0", false)
+	project.store(t, cache)
+	assert.NilError(t, os.WriteFile(project.targetFile, []byte("modified"), 0o600))
+
+	result, _, err := cache.Lookup(project.binaryModelFile, true)
+	assert.NilError(t, err)
+	assert.Assert(t, result != nil)
+	assert.Equal(t, true, result.Stale)
+	content, err := os.ReadFile(project.targetFile)
+	assert.NilError(t, err)
+	assert.Equal(t, "modified", string(content))
+
+	result, _, err = cache.Lookup(project.binaryModelFile, false)
+	assert.NilError(t, err)
+	assert.Assert(t, result != nil)
+	content, err = os.ReadFile(project.targetFile)
+	assert.NilError(t, err)
+	assert.Equal(t, "compiled code", string(content))
+}
+
+func TestBuildCacheMiss(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, project *buildCacheTestProject)
+	}{
+		{
+			name: "function file modified",
+			change: func(t *testing.T, project *buildCacheTestProject) {
+				assert.NilError(t, os.WriteFile(project.functionFile, []byte("Log::info() { echo; }"), 0o600))
+			},
+		},
+		{
+			name: "function file added in srcDirs",
+			change: func(t *testing.T, project *buildCacheTestProject) {
+				assert.NilError(t, os.WriteFile(filepath.Join(project.srcDir, "Log", "_.sh"), []byte(""), 0o600))
+			},
+		},
+		{
+			name: "template modified",
+			change: func(t *testing.T, project *buildCacheTestProject) {
+				templateFile := filepath.Join(project.templateDir, "binFile.gtpl")
+				assert.NilError(t, os.WriteFile(templateFile, []byte("{{ .vars }}"), 0o600))
+			},
+		},
+		{
+			name: "variable used by binary model modified",
+			change: func(t *testing.T, _ *buildCacheTestProject) {
+				t.Setenv("CACHE_TEST_DIR", t.TempDir())
+			},
+		},
+		{
+			name: "binary model removed",
+			change: func(t *testing.T, project *buildCacheTestProject) {
+				assert.NilError(t, os.Remove(project.binaryModelFile))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := newBuildCacheTestProject(t)
+			cache := NewBuildCache(t.TempDir(), "1.0.0", false)
+			project.store(t, cache)
+			tt.change(t, project)
+			result, _, err := cache.Lookup(project.binaryModelFile, false)
+			assert.NilError(t, err)
+			assert.Assert(t, result == nil)
+		})
+	}
+
+	t.Run("other compiler version", func(t *testing.T) {
+		project := newBuildCacheTestProject(t)
+		cacheDir := t.TempDir()
+		project.store(t, NewBuildCache(cacheDir, "1.0.0", false))
+		result, _, _ := NewBuildCache(cacheDir, "2.0.0", false).Lookup(project.binaryModelFile, false)
+		assert.Assert(t, result == nil)
+	})
+
+	t.Run("other output mode", func(t *testing.T) {
+		project := newBuildCacheTestProject(t)
+		cacheDir := t.TempDir()
+		project.store(t, NewBuildCache(cacheDir, "1.0.0", false))
+		result, _, _ := NewBuildCache(cacheDir, "1.0.0", true).Lookup(project.binaryModelFile, false)
+		assert.Assert(t, result == nil)
+	})
+}
+
+func TestBuildCacheModelVariables(t *testing.T) {
+	project := newBuildCacheTestProject(t)
+	err := os.WriteFile(
+		project.binaryModelFile,
+		[]byte("binData: {}\nvars:\n  CACHE_TEST_DIR: /fixed\ncompilerConfig:\n  srcDirs: [\"${CACHE_TEST_DIR}/src\"]\n"),
+		0o600,
+	)
+	assert.NilError(t, err)
+	cache := NewBuildCache(t.TempDir(), "1.0.0", false)
+	project.store(t, cache)
+	// the variable defined by the binary model takes precedence
+	t.Setenv("CACHE_TEST_DIR", t.TempDir())
+	result, _, err := cache.Lookup(project.binaryModelFile, false)
+	assert.NilError(t, err)
+	assert.Assert(t, result != nil)
+}
+
+func TestBuildCachePostCompileCommands(t *testing.T) {
+	storeWithCommand := func(t *testing.T, project *buildCacheTestProject, cache *BuildCache, command string) {
+		t.Helper()
+		result, err := newBinaryModelResult(project.binaryModelFile, project.targetFile, "compiled code")
+		assert.NilError(t, err)
+		result.PostCompileCommands = []PostCompileCommandResult{
+			{Command: command, ExitCode: 0, Output: "", DurationMs: 0},
+		}
+		dependencies := newBinaryModelDependencies(project.binaryModelFile, nil)
+		dependencies.targetFile = project.targetFile
+		assert.NilError(t, cache.Store(result, dependencies))
+	}
+	t.Run("not run if up to date", func(t *testing.T) {
+		project := newBuildCacheTestProject(t)
+		cache := NewBuildCache(t.TempDir(), "1.0.0", false)
+		storeWithCommand(t, project, cache, `echo "$1" >> "`+project.targetFile+`.log"`)
+		result, _, err := cache.Lookup(project.binaryModelFile, false)
+		assert.NilError(t, err)
+		assert.Equal(t, 0, len(result.PostCompileCommands))
+		_, err = os.Stat(project.targetFile + ".log")
+		assert.Assert(t, os.IsNotExist(err))
+	})
+	t.Run("run on restore", func(t *testing.T) {
+		project := newBuildCacheTestProject(t)
+		cache := NewBuildCache(t.TempDir(), "1.0.0", false)
+		storeWithCommand(t, project, cache, `echo "$1" >> "`+project.targetFile+`.log"`)
+		assert.NilError(t, os.Remove(project.targetFile))
+		result, _, err := cache.Lookup(project.binaryModelFile, false)
+		assert.NilError(t, err)
+		assert.Equal(t, 1, len(result.PostCompileCommands))
+		content, err := os.ReadFile(project.targetFile + ".log")
+		assert.NilError(t, err)
+		assert.Equal(t, project.targetFile+"\n", string(content))
+	})
+	t.Run("failure removes the entry", func(t *testing.T) {
+		project := newBuildCacheTestProject(t)
+		cache := NewBuildCache(t.TempDir(), "1.0.0", false)
+		storeWithCommand(t, project, cache, "exit 3")
+		assert.NilError(t, os.Remove(project.targetFile))
+		result, _, err := cache.Lookup(project.binaryModelFile, false)
+		assert.Error(t, err, "post compile commands failed on "+project.targetFile+": exit 3")
+		assert.Equal(t, 3, result.PostCompileCommands[0].ExitCode)
+		result, _, err = cache.Lookup(project.binaryModelFile, false)
+		assert.NilError(t, err)
+		assert.Assert(t, result == nil)
+	})
+}
+
+func TestBuildCacheSourceMap(t *testing.T) {
+	t.Run("not emitted", func(t *testing.T) {
+		project := newBuildCacheTestProject(t)
+		cache := NewBuildCache(t.TempDir(), "1.0.0", false)
+		project.store(t, cache)
+		assert.NilError(t, os.Remove(project.targetFile))
+		result, _, err := cache.Lookup(project.binaryModelFile, false)
+		assert.NilError(t, err)
+		assert.Assert(t, result != nil)
+		_, err = os.Stat(project.targetFile + compiler.SourceMapFileSuffix)
+		assert.Assert(t, os.IsNotExist(err))
+	})
+	t.Run("emitted", func(t *testing.T) {
+		project := newBuildCacheTestProject(t)
+		cache := NewBuildCache(t.TempDir(), "1.0.0", false)
+		result, err := newBinaryModelResult(project.binaryModelFile, project.targetFile, "compiled code")
+		assert.NilError(t, err)
+		result.setSourceMap(&compiler.SourceMap{File: "bin1", LineCount: 1, Mappings: []compiler.SourceMapping{
+			{StartLine: 1, EndLine: 1, Kind: compiler.SourceMappingKindTemplate, SrcFile: "binFile.gtpl"},
+		}})
+		// the target file is stale as long as its source map is missing
+		assert.Equal(t, true, result.Stale)
+		dependencies := newBinaryModelDependencies(project.binaryModelFile, nil)
+		dependencies.targetFile = project.targetFile
+		assert.NilError(t, cache.Store(result, dependencies))
+
+		result, _, err = cache.Lookup(project.binaryModelFile, true)
+		assert.NilError(t, err)
+		assert.Equal(t, true, result.Stale)
+		result, _, err = cache.Lookup(project.binaryModelFile, false)
+		assert.NilError(t, err)
+		assert.Equal(t, true, result.Stale)
+		sourceMap, err := compiler.LoadSourceMap(project.targetFile)
+		assert.NilError(t, err)
+		assert.Equal(t, "binFile.gtpl", sourceMap.Mappings[0].SrcFile)
+		result, _, err = cache.Lookup(project.binaryModelFile, true)
+		assert.NilError(t, err)
+		assert.Equal(t, false, result.Stale)
+	})
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/fchastanet/bash-compiler/internal/compiler"
+)
+
+// ComputeDependencyGraphs analyzes each binary model without rendering
+// the binary files and returns the dependency graph of each of them
+func (service *CompilerPipelineService) ComputeDependencyGraphs() ([]*compiler.DependencyGraph, error) {
+	binaryModelFilePaths, err := service.getBinaryModelFilePaths()
+	if err != nil {
+		return nil, err
+	}
+	graphs := make([]*compiler.DependencyGraph, 0, len(binaryModelFilePaths))
+	for _, binaryModelFilePath := range binaryModelFilePaths {
+		graph, err := service.analyzeBinaryModel(binaryModelFilePath)
+		if err != nil {
+			return nil, err
+		}
+		graphs = append(graphs, graph)
+	}
+	return graphs, nil
+}
+
+func (service *CompilerPipelineService) analyzeBinaryModel(
+	binaryModelFilePath string,
+) (*compiler.DependencyGraph, error) {
+	defaultLogger := slog.Default()
+	slog.SetDefault(defaultLogger.With("binaryModelFilePath", binaryModelFilePath))
+	defer slog.SetDefault(defaultLogger)
+
+	binaryModelServiceContextData, err := service.binaryModelService.Init(
+		service.options.IntermediateFilesDir,
+		binaryModelFilePath,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return service.binaryModelService.Analyze(binaryModelServiceContextData)
+}
+
+// WriteReferenceChains writes for each graph the chain of references
+// explaining why the function is included
+func WriteReferenceChains(
+	output io.Writer, graphs []*compiler.DependencyGraph, functionName string,
+) error {
+	for _, graph := range graphs {
+		chain, err := graph.ReferenceChain(functionName)
+		line := ""
+		if err != nil {
+			line = err.Error()
+		} else {
+			line = graph.FormatReferenceChain(chain)
+		}
+		_, err = fmt.Fprintf(output, "%s: %s\n", graph.BinaryModelFilePath, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
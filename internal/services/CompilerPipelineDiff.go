@@ -0,0 +1,58 @@
+package services
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/diffhelper"
+	"github.com/fchastanet/bash-compiler/internal/utils/files"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+const devNull = "/dev/null"
+
+// computeDiff sets the unified diff of the result, file names are relative
+// to rootDirectory so that the patch can be applied using git apply
+func computeDiff(rootDirectory string, result *BinaryModelResult) (err error) {
+	targetFile := result.TargetFile
+	relativeTargetFile, err := filepath.Rel(rootDirectory, targetFile)
+	if err == nil && !strings.HasPrefix(relativeTargetFile, "..") {
+		targetFile = relativeTargetFile
+	}
+	fromFile := "a/" + strings.TrimPrefix(targetFile, "/")
+	if !result.targetFileExists {
+		fromFile = devNull
+	}
+	result.Diff, err = diffhelper.UnifiedDiff(
+		fromFile, "b/"+strings.TrimPrefix(targetFile, "/"),
+		result.previousCode, result.code,
+	)
+	return err
+}
+
+// writeDiffs writes the diffs of the results in output and in diffFile if provided
+func writeDiffs(results []*BinaryModelResult, output io.Writer, diffFile string) error {
+	var patch strings.Builder
+	for _, result := range results {
+		patch.WriteString(result.Diff)
+	}
+	if patch.Len() == 0 {
+		slog.Info("No difference with existing binary files", "binaryFilesCount", len(results))
+	}
+	_, err := io.WriteString(output, patch.String())
+	if err != nil {
+		return err
+	}
+	if diffFile == "" {
+		return nil
+	}
+	err = os.WriteFile(diffFile, []byte(patch.String()), files.AllReadPerm)
+	if logger.FancyHandleError(err) {
+		return err
+	}
+	slog.Info("Patch file written", logger.LogFieldFilePath, diffFile)
+	return nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestComputeDiff(t *testing.T) {
+	t.Run("existing target file", func(t *testing.T) {
+		result := &BinaryModelResult{ //nolint:exhaustruct //test
+			TargetFile:       "/project/bin/a",
+			targetFileExists: true,
+			previousCode:     "echo a\n",
+			code:             "echo b\n",
+		}
+		err := computeDiff("/project", result)
+		assert.NilError(t, err)
+		assert.Equal(t, "--- a/bin/a\n+++ b/bin/a\n@@ -1 +1 @@\n-echo a\n+echo b\n", result.Diff)
+	})
+	t.Run("new target file outside root directory", func(t *testing.T) {
+		result := &BinaryModelResult{ //nolint:exhaustruct //test
+			TargetFile:       "/other/bin/a",
+			targetFileExists: false,
+			previousCode:     "",
+			code:             "echo b\n",
+		}
+		err := computeDiff("/project", result)
+		assert.NilError(t, err)
+		assert.Equal(t, "--- /dev/null\n+++ b/other/bin/a\n@@ -0,0 +1 @@\n+echo b\n", result.Diff)
+	})
+}
+
+func TestWriteDiffs(t *testing.T) {
+	results := []*BinaryModelResult{
+		{BinaryModelFilePath: "a-binary.yaml", Diff: "diff a\n"}, //nolint:exhaustruct //test
+		{BinaryModelFilePath: "b-binary.yaml", Diff: ""},         //nolint:exhaustruct //test
+		{BinaryModelFilePath: "c-binary.yaml", Diff: "diff c\n"}, //nolint:exhaustruct //test
+	}
+	diffFile := filepath.Join(t.TempDir(), "binaries.patch")
+	var output bytes.Buffer
+	err := writeDiffs(results, &output, diffFile)
+	assert.NilError(t, err)
+	assert.Equal(t, "diff a\ndiff c\n", output.String())
+	patch, err := os.ReadFile(diffFile)
+	assert.NilError(t, err)
+	assert.Equal(t, "diff a\ndiff c\n", string(patch))
+}
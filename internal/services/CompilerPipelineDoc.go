@@ -0,0 +1,330 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	htmlTemplate "html/template"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/fchastanet/bash-compiler/internal/compiler"
+	"github.com/fchastanet/bash-compiler/internal/utils/files"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+	"github.com/fchastanet/bash-compiler/internal/utils/structures"
+)
+
+//go:embed docTemplates
+var docTemplatesFs embed.FS
+
+const (
+	DocFormatMarkdown = "markdown"
+	DocFormatHTML     = "html"
+
+	binaryDocPagePrefix = "binary-"
+	docIndexPageName    = "index"
+)
+
+type unknownDocFormatError struct {
+	error
+	Format string
+}
+
+func (e *unknownDocFormatError) Error() string {
+	return "unknown documentation format: " + e.Format
+}
+
+// Documentation is the reference documentation of the functions available in the
+// srcDirs of the binary models and the list of the functions included in each binary
+type Documentation struct {
+	Functions map[string]*compiler.FunctionDoc
+	Binaries  []*BinaryDoc
+	// usedBy lists for each function the functions calling or requiring it
+	usedBy map[string][]string
+}
+
+// BinaryDoc lists the functions included in a binary
+type BinaryDoc struct {
+	Binary              string
+	BinaryModelFilePath string
+	Functions           []string
+}
+
+type docPage struct {
+	fileName     string
+	templateName string
+	data         any
+}
+
+// ComputeDocumentation analyzes each binary model and parses the shdoc comments
+// of the functions of their srcDirs, the first srcDir containing a function wins
+func (service *CompilerPipelineService) ComputeDocumentation() (*Documentation, error) {
+	binaryModelFilePaths, err := service.getBinaryModelFilePaths()
+	if err != nil {
+		return nil, err
+	}
+	documentation := &Documentation{
+		Functions: map[string]*compiler.FunctionDoc{},
+		Binaries:  make([]*BinaryDoc, 0, len(binaryModelFilePaths)),
+		usedBy:    map[string][]string{},
+	}
+	for _, binaryModelFilePath := range binaryModelFilePaths {
+		err = service.documentBinaryModel(documentation, binaryModelFilePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	documentation.computeUsedBy()
+	return documentation, nil
+}
+
+func (service *CompilerPipelineService) documentBinaryModel(
+	documentation *Documentation, binaryModelFilePath string,
+) error {
+	defaultLogger := slog.Default()
+	slog.SetDefault(defaultLogger.With("binaryModelFilePath", binaryModelFilePath))
+	defer slog.SetDefault(defaultLogger)
+
+	binaryModelServiceContextData, err := service.binaryModelService.Init(
+		service.options.IntermediateFilesDir,
+		binaryModelFilePath,
+	)
+	if err != nil {
+		return err
+	}
+	srcDirs := structures.ExpandStringList(binaryModelServiceContextData.binaryModelData.CompilerConfig.SrcDirs)
+	srcDirsFunctions, err := compiler.ListSrcDirsFunctions(srcDirs)
+	if err != nil {
+		return err
+	}
+	for functionName, srcFile := range srcDirsFunctions {
+		err = documentation.addFunction(functionName, srcFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	graph, err := service.binaryModelService.Analyze(binaryModelServiceContextData)
+	if err != nil {
+		return err
+	}
+	binaryDoc := &BinaryDoc{
+		Binary:              graph.Binary,
+		BinaryModelFilePath: binaryModelFilePath,
+		Functions:           []string{},
+	}
+	for _, node := range graph.Nodes {
+		if node.Kind != compiler.DependencyNodeKindFunction {
+			continue
+		}
+		err = documentation.addFunction(node.ID, node.SrcFile)
+		if err != nil {
+			return err
+		}
+		binaryDoc.Functions = append(binaryDoc.Functions, node.ID)
+	}
+	documentation.Binaries = append(documentation.Binaries, binaryDoc)
+	return nil
+}
+
+func (documentation *Documentation) addFunction(functionName string, srcFile string) error {
+	if _, exists := documentation.Functions[functionName]; exists {
+		return nil
+	}
+	functionDoc, err := compiler.ParseFunctionDoc(functionName, srcFile)
+	if err != nil {
+		return err
+	}
+	documentation.Functions[functionName] = functionDoc
+	return nil
+}
+
+func (documentation *Documentation) computeUsedBy() {
+	for _, functionName := range documentation.getSortedFunctionNames() {
+		functionDoc := documentation.Functions[functionName]
+		for _, usedFunction := range slices.Concat(functionDoc.Requires, functionDoc.Calls) {
+			documentation.usedBy[usedFunction] = append(documentation.usedBy[usedFunction], functionName)
+		}
+	}
+}
+
+func (documentation *Documentation) getSortedFunctionNames() []string {
+	functionNames := structures.MapKeys(documentation.Functions)
+	sort.Strings(functionNames)
+	return functionNames
+}
+
+// getNamespaces returns the functions of each namespace
+func (documentation *Documentation) getNamespaces() map[string][]*compiler.FunctionDoc {
+	namespaces := map[string][]*compiler.FunctionDoc{}
+	for _, functionName := range documentation.getSortedFunctionNames() {
+		functionDoc := documentation.Functions[functionName]
+		namespaces[functionDoc.Namespace] = append(namespaces[functionDoc.Namespace], functionDoc)
+	}
+	return namespaces
+}
+
+// WriteDocumentation writes an index page, a page per namespace and a page per binary
+// in outputDir using the given format (markdown or html)
+func WriteDocumentation(outputDir string, format string, documentation *Documentation) ([]string, error) {
+	extension, render, err := documentation.getRenderer(format)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := documentation.getNamespaces()
+	namespaceNames := structures.MapKeys(namespaces)
+	sort.Strings(namespaceNames)
+	pages := []docPage{{
+		fileName:     docIndexPageName + extension,
+		templateName: "index",
+		data: map[string]any{
+			"Namespaces": namespaceNames,
+			"Binaries":   documentation.Binaries,
+		},
+	}}
+	for _, namespace := range namespaceNames {
+		pages = append(pages, docPage{
+			fileName:     getNamespacePageName(namespace, extension),
+			templateName: "namespace",
+			data: map[string]any{
+				"Namespace": namespace,
+				"Functions": namespaces[namespace],
+			},
+		})
+	}
+	for _, binaryDoc := range documentation.Binaries {
+		functionDocs := make([]*compiler.FunctionDoc, 0, len(binaryDoc.Functions))
+		for _, functionName := range binaryDoc.Functions {
+			functionDocs = append(functionDocs, documentation.Functions[functionName])
+		}
+		pages = append(pages, docPage{
+			fileName:     binaryDocPagePrefix + binaryDoc.Binary + extension,
+			templateName: "binary",
+			data: map[string]any{
+				"Binary":    binaryDoc,
+				"Functions": functionDocs,
+			},
+		})
+	}
+
+	err = os.MkdirAll(outputDir, files.AllReadExecutePerm)
+	if err != nil {
+		return nil, err
+	}
+	writtenFiles := make([]string, 0, len(pages))
+	for _, page := range pages {
+		var content bytes.Buffer
+		err = render(&content, page.templateName, page.data)
+		if err != nil {
+			return writtenFiles, err
+		}
+		filePath := filepath.Join(outputDir, page.fileName)
+		err = os.WriteFile(filePath, content.Bytes(), files.AllReadPerm)
+		if logger.FancyHandleError(err) {
+			return writtenFiles, err
+		}
+		writtenFiles = append(writtenFiles, filePath)
+	}
+	slog.Info("Documentation written", "directory", outputDir, "pagesCount", len(writtenFiles))
+	return writtenFiles, nil
+}
+
+func getNamespacePageName(namespace string, extension string) string {
+	return strings.ReplaceAll(namespace, "::", ".") + extension
+}
+
+// getRenderer returns the extension of the pages and the function rendering them
+func (documentation *Documentation) getRenderer(format string) (
+	extension string, render func(output io.Writer, templateName string, data any) error, err error,
+) {
+	switch format {
+	case DocFormatMarkdown:
+		extension = ".md"
+		funcs := documentation.getTemplateFuncs(
+			extension, markdownFunctionLink,
+			func(text string) string { return text },
+			func(text string) any { return text },
+		)
+		markdownTemplates, err := template.New("markdown").Funcs(funcs).
+			ParseFS(docTemplatesFs, "docTemplates/*.md.gtpl")
+		if err != nil {
+			return "", nil, err
+		}
+		return extension, markdownTemplates.ExecuteTemplate, nil
+	case DocFormatHTML:
+		extension = ".html"
+		funcs := documentation.getTemplateFuncs(
+			extension, htmlFunctionLink, htmlTemplate.HTMLEscapeString,
+			// function names are checked and the rest of the text is escaped
+			func(text string) any { return htmlTemplate.HTML(text) }, //nolint:gosec // escaped by linkify
+		)
+		htmlTemplates, err := htmlTemplate.New("html").Funcs(funcs).
+			ParseFS(docTemplatesFs, "docTemplates/*.html.gtpl")
+		if err != nil {
+			return "", nil, err
+		}
+		return extension, htmlTemplates.ExecuteTemplate, nil
+	}
+	return "", nil, &unknownDocFormatError{nil, format}
+}
+
+// getTemplateFuncs returns the functions available in the templates,
+// toOutput marks the generated links as safe content
+func (documentation *Documentation) getTemplateFuncs(
+	extension string,
+	functionLink func(functionName string, page string) string,
+	escape func(text string) string,
+	toOutput func(text string) any,
+) map[string]any {
+	return map[string]any{
+		"linkify": func(text string) any {
+			return toOutput(documentation.linkify(text, extension, functionLink, escape))
+		},
+		"usedBy": func(functionName string) []string {
+			return documentation.usedBy[functionName]
+		},
+		"namespacePage": func(namespace string) string {
+			return getNamespacePageName(namespace, extension)
+		},
+		"binaryPage": func(binary string) string {
+			return binaryDocPagePrefix + binary + extension
+		},
+		"list": func(values ...any) []any {
+			return values
+		},
+		"firstLine": func(text string) string {
+			firstLine, _, _ := strings.Cut(text, "\n")
+			return firstLine
+		},
+	}
+}
+
+// linkify replaces the references to documented functions by links to their documentation
+func (documentation *Documentation) linkify(
+	text string,
+	extension string,
+	functionLink func(functionName string, page string) string,
+	escape func(text string) string,
+) string {
+	return compiler.ReplaceFrameworkFunctionReferences(text, func(functionName string) string {
+		functionDoc, documented := documentation.Functions[functionName]
+		if !documented {
+			return escape(functionName)
+		}
+		return functionLink(functionName, getNamespacePageName(functionDoc.Namespace, extension))
+	}, escape)
+}
+
+func markdownFunctionLink(functionName string, page string) string {
+	return "[" + functionName + "](" + page + "#" + functionName + ")"
+}
+
+func htmlFunctionLink(functionName string, page string) string {
+	return `<a href="` + htmlTemplate.HTMLEscapeString(page+"#"+functionName) + `"><code>` +
+		htmlTemplate.HTMLEscapeString(functionName) + `</code></a>`
+}
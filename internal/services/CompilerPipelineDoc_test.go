@@ -0,0 +1,101 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/compiler"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func newTestFunctionDoc(functionName string, description string, requires []string) *compiler.FunctionDoc {
+	return &compiler.FunctionDoc{
+		FunctionName: functionName,
+		Namespace:    compiler.GetFunctionNamespace(functionName),
+		SrcFile:      "/src/" + functionName + ".sh",
+		Description:  description,
+		Args:         []string{},
+		Options:      []string{},
+		Env:          []string{},
+		ExitCodes:    []string{},
+		Stdin:        []string{},
+		Stdout:       []string{},
+		Stderr:       []string{},
+		See:          []string{},
+		Example:      "",
+		Requires:     requires,
+		Calls:        []string{},
+	}
+}
+
+func newTestDocumentation() *Documentation {
+	documentation := &Documentation{
+		Functions: map[string]*compiler.FunctionDoc{
+			"Log::info":        newTestFunctionDoc("Log::info", "display <info> using Array::join", []string{}),
+			"Array::join":      newTestFunctionDoc("Array::join", "join the elements", []string{"Log::requireLevel"}),
+			"Log::Sub::notice": newTestFunctionDoc("Log::Sub::notice", "", []string{}),
+		},
+		Binaries: []*BinaryDoc{{
+			Binary:              "myBinary",
+			BinaryModelFilePath: "myBinary-binary.yaml",
+			Functions:           []string{"Array::join", "Log::info"},
+		}},
+		usedBy: map[string][]string{},
+	}
+	documentation.Functions["Log::info"].Calls = []string{"Array::join"}
+	documentation.computeUsedBy()
+	return documentation
+}
+
+func TestWriteDocumentation(t *testing.T) {
+	t.Run("markdown", func(t *testing.T) {
+		outputDir := t.TempDir()
+		writtenFiles, err := WriteDocumentation(outputDir, DocFormatMarkdown, newTestDocumentation())
+		assert.NilError(t, err)
+		assert.DeepEqual(t, []string{
+			filepath.Join(outputDir, "index.md"),
+			filepath.Join(outputDir, "Array.md"),
+			filepath.Join(outputDir, "Log.md"),
+			filepath.Join(outputDir, "Log.Sub.md"),
+			filepath.Join(outputDir, "binary-myBinary.md"),
+		}, writtenFiles)
+
+		content, err := os.ReadFile(filepath.Join(outputDir, "Array.md"))
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(string(content), "<a id=\"Array::join\"></a>\n\n## Array::join\n"))
+		// undocumented required function is not linked
+		assert.Assert(t, cmp.Contains(string(content), "### Requires\n\n- Log::requireLevel\n"))
+		assert.Assert(t, cmp.Contains(string(content), "### Used by\n\n- [Log::info](Log.md#Log::info)\n"))
+
+		content, err = os.ReadFile(filepath.Join(outputDir, "Log.md"))
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(string(content), "display <info> using [Array::join](Array.md#Array::join)"))
+
+		content, err = os.ReadFile(filepath.Join(outputDir, "binary-myBinary.md"))
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(string(content), "| [Array::join](Array.md#Array::join) | join the elements |"))
+		assert.Assert(t, !strings.Contains(string(content), "Log::Sub::notice"))
+	})
+
+	t.Run("html", func(t *testing.T) {
+		outputDir := t.TempDir()
+		_, err := WriteDocumentation(outputDir, DocFormatHTML, newTestDocumentation())
+		assert.NilError(t, err)
+		content, err := os.ReadFile(filepath.Join(outputDir, "Log.html"))
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(string(content),
+			`display &lt;info&gt; using <a href="Array.html#Array::join"><code>Array::join</code></a>`))
+		content, err = os.ReadFile(filepath.Join(outputDir, "index.html"))
+		assert.NilError(t, err)
+		assert.Assert(t, cmp.Contains(string(content), `<a href="Log.Sub.html">Log::Sub</a>`))
+		assert.Assert(t, cmp.Contains(string(content), `<a href="binary-myBinary.html">myBinary</a>`))
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := WriteDocumentation(t.TempDir(), "pdf", newTestDocumentation())
+		assert.Error(t, err, "unknown documentation format: pdf")
+	})
+}
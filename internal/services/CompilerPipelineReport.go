@@ -0,0 +1,54 @@
+package services
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/files"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+// buildReport is the machine readable report of a compilation
+type buildReport struct {
+	Success    bool                 `json:"success"`
+	StartTime  time.Time            `json:"startTime"`
+	DurationMs int64                `json:"durationMs"`
+	Error      string               `json:"error,omitempty"`
+	Binaries   []*BinaryModelResult `json:"binaries"`
+}
+
+func newBuildReport(results []*BinaryModelResult, duration time.Duration, err error) *buildReport {
+	report := &buildReport{
+		Success:    err == nil,
+		StartTime:  time.Now().Add(-duration).UTC().Truncate(time.Millisecond),
+		DurationMs: duration.Milliseconds(),
+		Error:      "",
+		Binaries:   results,
+	}
+	if report.Binaries == nil {
+		report.Binaries = []*BinaryModelResult{}
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// writeReport writes the json report of the compilation in reportFile,
+// the binaries compiled before a failure are reported as well
+func writeReport(
+	reportFile string, results []*BinaryModelResult, duration time.Duration, err error,
+) error {
+	content, jsonErr := json.MarshalIndent(newBuildReport(results, duration, err), "", "  ")
+	if logger.FancyHandleError(jsonErr) {
+		return jsonErr
+	}
+	jsonErr = os.WriteFile(reportFile, append(content, '\n'), files.AllReadPerm)
+	if logger.FancyHandleError(jsonErr) {
+		return jsonErr
+	}
+	slog.Info("Report file written", logger.LogFieldFilePath, reportFile)
+	return nil
+}
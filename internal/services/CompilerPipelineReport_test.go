@@ -0,0 +1,42 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWriteReport(t *testing.T) {
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	results := []*BinaryModelResult{
+		{BinaryModelFilePath: "a-binary.yaml", TargetFile: "bin/a", DurationMs: 12}, //nolint:exhaustruct // test
+		{BinaryModelFilePath: "b-binary.yaml", Error: "boom"},                       //nolint:exhaustruct // test
+	}
+	err := writeReport(reportFile, results, 1500*time.Millisecond, errors.New("boom"))
+	assert.NilError(t, err)
+
+	content, err := os.ReadFile(reportFile)
+	assert.NilError(t, err)
+	report := buildReport{} //nolint:exhaustruct // test
+	err = json.Unmarshal(content, &report)
+	assert.NilError(t, err)
+	assert.Assert(t, !report.Success)
+	assert.Equal(t, "boom", report.Error)
+	assert.Equal(t, int64(1500), report.DurationMs)
+	assert.Equal(t, 2, len(report.Binaries))
+	assert.Equal(t, "bin/a", report.Binaries[0].TargetFile)
+	assert.Equal(t, int64(12), report.Binaries[0].DurationMs)
+	assert.Equal(t, "boom", report.Binaries[1].Error)
+}
+
+func TestNewBuildReportWithoutResults(t *testing.T) {
+	report := newBuildReport(nil, time.Second, nil)
+	assert.Assert(t, report.Success)
+	assert.Equal(t, "", report.Error)
+	assert.Equal(t, 0, len(report.Binaries))
+}
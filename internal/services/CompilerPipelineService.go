@@ -1,11 +1,18 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/fchastanet/bash-compiler/internal/compiler"
 	"github.com/fchastanet/bash-compiler/internal/model"
@@ -16,6 +23,13 @@ import (
 	"github.com/fchastanet/bash-compiler/internal/utils/logger"
 )
 
+const (
+	// customAnnotationEnvPrefix is the prefix of the variables of .bash-compiler declaring annotations
+	customAnnotationEnvPrefix             = "ANNOTATION_"
+	customAnnotationRegexpEnvSuffix       = "_REGEXP"
+	customAnnotationTemplateNameEnvSuffix = "_TEMPLATE_NAME"
+)
+
 type missingTemplateRootDir struct {
 	error
 }
@@ -39,36 +53,79 @@ func (err *invalidTemplateRootDir) Error() string {
 	)
 }
 
+type staleBinariesError struct {
+	error
+	StaleFiles []string
+}
+
+func (err *staleBinariesError) Error() string {
+	return fmt.Sprintf(
+		"%d binary file(s) out of date : %s",
+		len(err.StaleFiles), strings.Join(err.StaleFiles, ", "),
+	)
+}
+
+type deprecatedFunctionsError struct {
+	error
+	TargetFile string
+	Functions  []string
+}
+
+func (err *deprecatedFunctionsError) Error() string {
+	return fmt.Sprintf(
+		"%s includes %d deprecated function(s) : %s",
+		err.TargetFile, len(err.Functions), strings.Join(err.Functions, ", "),
+	)
+}
+
+// CompilerPipelineOptions are the options of the compiler pipeline,
+// the zero value is suitable for the commands that do not compile binaries
+type CompilerPipelineOptions struct {
+	RootDirectory        string
+	YamlFiles            []string
+	BinaryFilesExtension string
+	Debug                bool
+	IntermediateFilesDir string
+	// Check reports the binaries out of date instead of writing them
+	Check bool
+	// Diff displays the differences with the existing binaries instead of writing them
+	Diff bool
+	// DiffFile writes the differences in this file instead of stdout, implies Diff
+	DiffFile string
+	// Jobs is the number of binary models compiled in parallel, 0 for the number of CPUs
+	Jobs       int
+	ReportFile string
+	Release    bool
+	// DenyDeprecated fails the compilation if a binary includes a deprecated function
+	DenyDeprecated bool
+	// BuildCache skips the compilation of the binaries whose inputs did not change, nil to disable it
+	BuildCache *BuildCache
+	// WorkerCommandFactory is used to compile binary models in parallel, nil to compile them in process
+	WorkerCommandFactory WorkerCommandFactory
+}
+
 type CompilerPipelineService struct {
-	rootDirectory        string
-	yamlFiles            []string
-	binaryFilesExtension string
-	debug                bool
-	intermediateFilesDir string
-
-	binaryModelService *BinaryModelServiceContext
-}
-
-func NewCompilerPipelineService(
-	rootDirectory string,
-	yamlFiles []string,
-	binaryFilesExtension string,
-	debug bool,
-	intermediateFilesDir string,
-) (_ *CompilerPipelineService) {
+	options CompilerPipelineOptions
+
+	binaryModelService      *BinaryModelServiceContext
+	binaryModelDependencies map[string]*binaryModelDependencies
+	// customAnnotationEnvNames are the ANNOTATION_ variables set by the config file
+	customAnnotationEnvNames []string
+}
+
+func NewCompilerPipelineService(options CompilerPipelineOptions) (_ *CompilerPipelineService) {
+	options.Diff = options.Diff || options.DiffFile != ""
 	return &CompilerPipelineService{
-		rootDirectory:        rootDirectory,
-		yamlFiles:            yamlFiles,
-		binaryFilesExtension: binaryFilesExtension,
-		debug:                debug,
-		intermediateFilesDir: intermediateFilesDir,
-		binaryModelService:   nil,
+		options:                  options,
+		binaryModelService:       nil,
+		binaryModelDependencies:  make(map[string]*binaryModelDependencies),
+		customAnnotationEnvNames: []string{},
 	}
 }
 
 func (service *CompilerPipelineService) Init() error {
 	// set useful env variables that can be interpolated during template rendering
-	err := setEnvVariable("ROOT_DIR", service.rootDirectory)
+	err := setEnvVariable("ROOT_DIR", service.options.RootDirectory)
 	if err != nil {
 		return err
 	}
@@ -78,7 +135,7 @@ func (service *CompilerPipelineService) Init() error {
 	if err != nil {
 		return err
 	}
-	if service.debug {
+	if service.options.Debug {
 		envVars := os.Environ()
 		for _, envVar := range envVars {
 			slog.Debug("env", "var", envVar)
@@ -94,18 +151,28 @@ func (service *CompilerPipelineService) initBinaryModelService() {
 	templateContext := render.NewTemplateContext()
 	requireAnnotationProcessor := compiler.NewRequireAnnotationProcessor()
 	embedAnnotationProcessor := compiler.NewEmbedAnnotationProcessor()
+	deprecatedAnnotationProcessor := compiler.NewDeprecatedAnnotationProcessor()
+	requireCommandAnnotationProcessor := compiler.NewRequireCommandAnnotationProcessor()
+	requireEnvAnnotationProcessor := compiler.NewRequireEnvAnnotationProcessor()
+	insertOrderAnnotationProcessor := compiler.NewInsertOrderAnnotationProcessor()
+	customAnnotationProcessor := compiler.NewCustomAnnotationProcessor(getGlobalCustomAnnotations())
 	compilerService := compiler.NewCompiler(
 		templateContext,
 		[]compiler.AnnotationProcessorInterface{
 			requireAnnotationProcessor,
 			embedAnnotationProcessor,
+			deprecatedAnnotationProcessor,
+			requireCommandAnnotationProcessor,
+			requireEnvAnnotationProcessor,
+			insertOrderAnnotationProcessor,
+			customAnnotationProcessor,
 		},
 	)
 	var templateContextInterface TemplateContextInterface = templateContext
 	var compilerInterface CodeCompilerInterface = compilerService
 	intermediateFileCallback := skipIntermediateFilesCallback
 	intermediateFileContentCallback := skipIntermediateFilesCallback
-	if service.intermediateFilesDir != "" {
+	if service.options.IntermediateFilesDir != "" {
 		intermediateFileCallback = logger.DebugCopyIntermediateFile
 		intermediateFileContentCallback = logger.DebugSaveIntermediateFile
 	}
@@ -119,44 +186,267 @@ func (service *CompilerPipelineService) initBinaryModelService() {
 }
 
 func (service *CompilerPipelineService) ProcessPipeline() error {
-	defaultLogger := slog.Default()
+	startTime := time.Now()
+	results, err := service.compileAllBinaryModels()
+	if service.options.ReportFile != "" {
+		reportErr := writeReport(service.options.ReportFile, results, time.Since(startTime), err)
+		if reportErr != nil {
+			return errors.Join(err, reportErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if service.options.Diff {
+		err = writeDiffs(results, os.Stdout, service.options.DiffFile)
+		if err != nil {
+			return err
+		}
+	}
+	if service.options.Check {
+		return checkStaleBinaries(results)
+	}
+	return nil
+}
 
-	err := service.computeYamlFiles()
+// ProcessWorker compiles the binary models and writes the results as json
+// in output, used by the processes launched in parallel by the main process
+func (service *CompilerPipelineService) ProcessWorker(output io.Writer) error {
+	binaryModelFilePaths, err := service.getBinaryModelFilePaths()
 	if err != nil {
 		return err
 	}
+	results := make([]*BinaryModelResult, 0, len(binaryModelFilePaths))
+	for _, binaryModelFilePath := range binaryModelFilePaths {
+		result, err := service.processBinaryModel(binaryModelFilePath)
+		results = append(results, result)
+		if err != nil {
+			// results are still written so that the error can be reported
+			return errors.Join(err, json.NewEncoder(output).Encode(results))
+		}
+	}
+	return json.NewEncoder(output).Encode(results)
+}
+
+func (service *CompilerPipelineService) compileAllBinaryModels() ([]*BinaryModelResult, error) {
+	binaryModelFilePaths, err := service.getBinaryModelFilePaths()
+	if err != nil {
+		return nil, err
+	}
+	jobsCount := getJobsCount(service.options.Jobs, len(binaryModelFilePaths))
+	if jobsCount > 1 && service.options.WorkerCommandFactory != nil {
+		return service.processBinaryModelsInWorkers(binaryModelFilePaths, jobsCount, os.Stderr)
+	}
+	results := make([]*BinaryModelResult, 0, len(binaryModelFilePaths))
+	for _, binaryModelFilePath := range binaryModelFilePaths {
+		result, err := service.processBinaryModel(binaryModelFilePath)
+		results = append(results, result)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+func checkStaleBinaries(results []*BinaryModelResult) error {
+	staleFiles := []string{}
+	for _, result := range results {
+		if result.Stale {
+			slog.Error(
+				"Binary file is out of date",
+				logger.LogFieldFilePath, result.TargetFile,
+				"binaryModelFilePath", result.BinaryModelFilePath,
+			)
+			staleFiles = append(staleFiles, result.TargetFile)
+		}
+	}
+	if len(staleFiles) > 0 {
+		return &staleBinariesError{nil, staleFiles}
+	}
+	slog.Info("All binary files are up to date", "binaryFilesCount", len(results))
+	return nil
+}
+
+// getBinaryModelFilePaths returns the binary model files to compile
+// skipping the ones excluded by FILTER_REGEX_EXCLUDE
+func (service *CompilerPipelineService) getBinaryModelFilePaths() ([]string, error) {
+	err := service.computeYamlFiles()
+	if err != nil {
+		return nil, err
+	}
 	filterRegexpExclude, _ := getFilterRegexpExclude()
-	for _, binaryModelFilePath := range service.yamlFiles {
-		if filterRegexpExclude != nil {
-			relativePath, err := filepath.Rel(service.rootDirectory, binaryModelFilePath)
-			if err != nil {
-				return err
-			}
-			slog.Debug("check if path needs to be excluded", "filepath", relativePath)
-			if filterRegexpExclude.MatchString(relativePath) {
-				slog.Info("Skipping file excluded by FILTER_REGEX_EXCLUDE", "filepath", relativePath)
-				continue
-			}
+	binaryModelFilePaths := make([]string, 0, len(service.options.YamlFiles))
+	for _, binaryModelFilePath := range service.options.YamlFiles {
+		excluded, err := service.isExcluded(filterRegexpExclude, binaryModelFilePath)
+		if err != nil {
+			return nil, err
 		}
-		slog.SetDefault(defaultLogger.With("binaryModelFilePath", binaryModelFilePath))
-		binaryModelServiceContextData, err := service.binaryModelService.Init(
-			service.intermediateFilesDir,
-			binaryModelFilePath,
+		if !excluded {
+			binaryModelFilePaths = append(binaryModelFilePaths, binaryModelFilePath)
+		}
+	}
+	return binaryModelFilePaths, nil
+}
+
+func (service *CompilerPipelineService) isExcluded(
+	filterRegexpExclude *regexp.Regexp,
+	binaryModelFilePath string,
+) (bool, error) {
+	if filterRegexpExclude == nil {
+		return false, nil
+	}
+	relativePath, err := filepath.Rel(service.options.RootDirectory, binaryModelFilePath)
+	if err != nil {
+		return false, err
+	}
+	slog.Debug("check if path needs to be excluded", "filepath", relativePath)
+	if filterRegexpExclude.MatchString(relativePath) {
+		slog.Info("Skipping file excluded by FILTER_REGEX_EXCLUDE", "filepath", relativePath)
+		return true, nil
+	}
+	return false, nil
+}
+
+// processBinaryModel compiles the binary model, the result is returned
+// even if the compilation fails in order to report the error and the timing
+func (service *CompilerPipelineService) processBinaryModel(
+	binaryModelFilePath string,
+) (*BinaryModelResult, error) {
+	startTime := time.Now()
+	result, err := service.compileBinaryModel(binaryModelFilePath)
+	if result == nil {
+		result = &BinaryModelResult{
+			BinaryModelFilePath: binaryModelFilePath,
+			TargetFile:          "",
+			Stale:               false,
+			Diff:                "",
+			Functions:           []compiler.IncludedFunction{},
+			EmbeddedResources:   []compiler.EmbeddedResource{},
+			SizeReduction:       nil,
+			DeprecatedFunctions: nil,
+			PostCompileCommands: nil,
+			DurationMs:          0,
+			Error:               "",
+			targetFileExists:    false,
+			previousCode:        "",
+			code:                "",
+		}
+	}
+	result.DurationMs = time.Since(startTime).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, err
+}
+
+func (service *CompilerPipelineService) compileBinaryModel(
+	binaryModelFilePath string,
+) (*BinaryModelResult, error) {
+	defaultLogger := slog.Default()
+	slog.SetDefault(defaultLogger.With("binaryModelFilePath", binaryModelFilePath))
+	defer slog.SetDefault(defaultLogger)
+
+	if service.options.BuildCache != nil {
+		result, dependencies, err := service.options.BuildCache.Lookup(
+			binaryModelFilePath, service.options.Check || service.options.Diff,
 		)
 		if err != nil {
-			return err
+			// result is kept if only the post compile commands failed
+			service.binaryModelDependencies[binaryModelFilePath] = dependencies
+			return result, err
 		}
-		err = service.binaryModelService.Compile(binaryModelServiceContextData)
-		if err != nil {
-			return err
+		if result != nil {
+			service.binaryModelDependencies[binaryModelFilePath] = dependencies
+			if service.options.Diff {
+				err = computeDiff(service.options.RootDirectory, result)
+			}
+			return result, errors.Join(err, service.checkDeprecatedFunctions(result))
 		}
 	}
+
+	binaryModelServiceContextData, err := service.binaryModelService.Init(
+		service.options.IntermediateFilesDir,
+		binaryModelFilePath,
+	)
+	if err != nil {
+		// keep previous dependencies if any so that a fix can trigger a new compilation
+		if _, exists := service.binaryModelDependencies[binaryModelFilePath]; !exists {
+			service.binaryModelDependencies[binaryModelFilePath] = newBinaryModelDependencies(
+				binaryModelFilePath, nil,
+			)
+		}
+		return nil, err
+	}
+	result, err := service.binaryModelService.Compile(
+		binaryModelServiceContextData, service.options.Check || service.options.Diff, service.options.Release,
+	)
+	// functions source files are only known once compiled
+	service.binaryModelDependencies[binaryModelFilePath] = newBinaryModelDependencies(
+		binaryModelFilePath, binaryModelServiceContextData,
+	)
+	if err != nil {
+		// result is kept if only the post compile commands failed
+		return result, err
+	}
+	if service.options.BuildCache != nil {
+		service.storeInBuildCache(result)
+	}
+	if service.options.Diff {
+		err = computeDiff(service.options.RootDirectory, result)
+	}
+	return result, errors.Join(err, service.checkDeprecatedFunctions(result))
+}
+
+// checkDeprecatedFunctions warns about the deprecated functions included in the binary,
+// an error is returned instead if deprecated functions are denied
+func (service *CompilerPipelineService) checkDeprecatedFunctions(result *BinaryModelResult) error {
+	if len(result.DeprecatedFunctions) == 0 {
+		return nil
+	}
+	log := slog.Warn
+	if service.options.DenyDeprecated {
+		log = slog.Error
+	}
+	functionNames := make([]string, 0, len(result.DeprecatedFunctions))
+	for _, usage := range result.DeprecatedFunctions {
+		log("Deprecated function included",
+			logger.LogFieldFilePath, result.TargetFile,
+			"function", usage.FunctionName,
+			"replacement", usage.Replacement,
+			"message", usage.Message,
+			"referenceChain", usage.ReferenceChain,
+		)
+		functionNames = append(functionNames, usage.FunctionName)
+	}
+	if service.options.DenyDeprecated {
+		return &deprecatedFunctionsError{nil, result.TargetFile, functionNames}
+	}
 	return nil
 }
 
+// storeInBuildCache records the compilation, a failure only prevents
+// the next compilation to be skipped
+func (service *CompilerPipelineService) storeInBuildCache(result *BinaryModelResult) {
+	dependencies := newBinaryModelDependencies(result.BinaryModelFilePath, nil)
+	for file := range service.binaryModelDependencies[result.BinaryModelFilePath].files {
+		dependencies.files[file] = true
+	}
+	dependencies.srcDirs = service.binaryModelDependencies[result.BinaryModelFilePath].srcDirs
+	dependencies.templateDirs = service.binaryModelDependencies[result.BinaryModelFilePath].templateDirs
+	// variables defined in config file are used by the binary model
+	configFile := filepath.Join(service.options.RootDirectory, configFileName)
+	if files.FileExists(configFile) == nil {
+		dependencies.addFiles([]string{configFile})
+	}
+	err := service.options.BuildCache.Store(result, dependencies)
+	if err != nil {
+		slog.Warn("Unable to update build cache", logger.LogFieldErr, err)
+	}
+}
+
 // load .bash-compiler file in current directory if exists
 func (service *CompilerPipelineService) loadConfFile() error {
-	configFile := filepath.Join(service.rootDirectory, ".bash-compiler")
+	configFile := filepath.Join(service.options.RootDirectory, ".bash-compiler")
 	err := files.FileExists(configFile)
 	if err != nil {
 		slog.Warn("Config file is not available or not readable", "configFile", configFile)
@@ -164,11 +454,19 @@ func (service *CompilerPipelineService) loadConfFile() error {
 	}
 	os.Unsetenv("TEMPLATES_ROOT_DIR")
 	os.Unsetenv("FILTER_REGEX_EXCLUDE")
+	unsetGlobalPostCompileCommands()
+	service.unsetGlobalCustomAnnotations()
+	existingCustomAnnotationEnvNames := getCustomAnnotationEnvNames()
 	slog.Info("Loading", logger.LogFieldFilePath, configFile)
 	err = dotenv.LoadEnvFile(configFile)
 	if err != nil {
 		return err
 	}
+	for _, name := range getCustomAnnotationEnvNames() {
+		if !slices.Contains(existingCustomAnnotationEnvNames, name) {
+			service.customAnnotationEnvNames = append(service.customAnnotationEnvNames, name)
+		}
+	}
 	templateRootDir, exists := os.LookupEnv("TEMPLATES_ROOT_DIR")
 	if !exists {
 		return &missingTemplateRootDir{nil}
@@ -206,11 +504,57 @@ func getFilterRegexpExclude() (*regexp.Regexp, error) {
 	return nil, nil
 }
 
+// getGlobalCustomAnnotations returns the annotations declared in .bash-compiler using
+// the variables ANNOTATION_<NAME>_REGEXP and ANNOTATION_<NAME>_TEMPLATE_NAME
+func getGlobalCustomAnnotations() []model.CustomAnnotationConfig {
+	annotations := []model.CustomAnnotationConfig{}
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		annotationName, isRegexp := strings.CutSuffix(
+			strings.TrimPrefix(name, customAnnotationEnvPrefix), customAnnotationRegexpEnvSuffix,
+		)
+		if !strings.HasPrefix(name, customAnnotationEnvPrefix) || !isRegexp || value == "" {
+			continue
+		}
+		annotations = append(annotations, model.CustomAnnotationConfig{
+			Name:   strings.ToLower(annotationName),
+			Regexp: value,
+			TemplateName: os.Getenv(
+				customAnnotationEnvPrefix + annotationName + customAnnotationTemplateNameEnvSuffix,
+			),
+		})
+	}
+	sort.Slice(annotations, func(i, j int) bool {
+		return annotations[i].Name < annotations[j].Name
+	})
+	return annotations
+}
+
+func getCustomAnnotationEnvNames() []string {
+	names := []string{}
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(name, customAnnotationEnvPrefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// unsetGlobalCustomAnnotations unsets the annotations variables set by the previously
+// loaded config file, the variables of the environment are kept
+func (service *CompilerPipelineService) unsetGlobalCustomAnnotations() {
+	for _, name := range service.customAnnotationEnvNames {
+		os.Unsetenv(name)
+	}
+	service.customAnnotationEnvNames = []string{}
+}
+
 func (service *CompilerPipelineService) computeYamlFiles() (err error) {
-	if len(service.yamlFiles) == 0 {
+	if len(service.options.YamlFiles) == 0 {
 		filesList, err := files.MatchPatterns(
-			service.rootDirectory,
-			"**/*"+service.binaryFilesExtension,
+			service.options.RootDirectory,
+			"**/*"+service.options.BinaryFilesExtension,
 		)
 		if err != nil {
 			return err
@@ -218,12 +562,12 @@ func (service *CompilerPipelineService) computeYamlFiles() (err error) {
 		if len(filesList) == 0 {
 			slog.Error(
 				"cannot find any file with specified suffix and directory",
-				"rootDirectory", service.rootDirectory,
-				"extension", service.binaryFilesExtension,
+				"rootDirectory", service.options.RootDirectory,
+				"extension", service.options.BinaryFilesExtension,
 			)
 			return err
 		}
-		service.yamlFiles = filesList
+		service.options.YamlFiles = filesList
 	}
 	return nil
 }
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/compiler"
+	"github.com/fchastanet/bash-compiler/internal/model"
+	"gotest.tools/v3/assert"
+)
+
+func TestCheckStaleBinaries(t *testing.T) {
+	upToDate := &BinaryModelResult{BinaryModelFilePath: "a-binary.yaml", TargetFile: "bin/a", Stale: false}
+	t.Run("up to date", func(t *testing.T) {
+		assert.NilError(t, checkStaleBinaries([]*BinaryModelResult{upToDate}))
+	})
+	t.Run("stale", func(t *testing.T) {
+		err := checkStaleBinaries([]*BinaryModelResult{
+			{BinaryModelFilePath: "b-binary.yaml", TargetFile: "bin/b", Stale: true},
+			upToDate,
+			{BinaryModelFilePath: "c-binary.yaml", TargetFile: "bin/c", Stale: true},
+		})
+		assert.Error(t, err, "2 binary file(s) out of date : bin/b, bin/c")
+	})
+}
+
+func TestCheckDeprecatedFunctions(t *testing.T) {
+	result := &BinaryModelResult{ //nolint:exhaustruct // test
+		TargetFile: "bin/a",
+		DeprecatedFunctions: []compiler.DeprecatedFunctionUsage{
+			{FunctionName: "Old::a", Replacement: "New::a", Message: "", ReferenceChain: "a -[call]-> Old::a"},
+			{FunctionName: "Old::b", Replacement: "", Message: "", ReferenceChain: "a -[call]-> Old::b"},
+		},
+	}
+	t.Run("warning only", func(t *testing.T) {
+		service := NewCompilerPipelineService(CompilerPipelineOptions{DenyDeprecated: false}) //nolint:exhaustruct // test
+		assert.NilError(t, service.checkDeprecatedFunctions(result))
+	})
+	t.Run("denied", func(t *testing.T) {
+		service := NewCompilerPipelineService(CompilerPipelineOptions{DenyDeprecated: true}) //nolint:exhaustruct // test
+		assert.Error(t, service.checkDeprecatedFunctions(result),
+			"bin/a includes 2 deprecated function(s) : Old::a, Old::b")
+		assert.NilError(t, service.checkDeprecatedFunctions(&BinaryModelResult{})) //nolint:exhaustruct // test
+	})
+}
+
+func TestGetGlobalCustomAnnotations(t *testing.T) {
+	t.Setenv("ANNOTATION_EXPERIMENTAL_REGEXP", "^# @experimental$")
+	t.Setenv("ANNOTATION_EXPERIMENTAL_TEMPLATE_NAME", "experimentalTemplateName")
+	t.Setenv("ANNOTATION_AUDIT_REGEXP", "^# @audit (?P<value>.*)$")
+	t.Setenv("ANNOTATION_AUDIT_TEMPLATE_NAME", "auditTemplateName")
+	t.Setenv("ANNOTATION_EMPTY_REGEXP", "")
+	assert.DeepEqual(t, []model.CustomAnnotationConfig{
+		{Name: "audit", Regexp: "^# @audit (?P<value>.*)$", TemplateName: "auditTemplateName"},
+		{Name: "experimental", Regexp: "^# @experimental$", TemplateName: "experimentalTemplateName"},
+	}, getGlobalCustomAnnotations())
+}
+
+func TestLoadConfFileCustomAnnotations(t *testing.T) {
+	rootDirectory := t.TempDir()
+	t.Setenv("TEMPLATES_ROOT_DIR", "")
+	t.Setenv("FILTER_REGEX_EXCLUDE", "")
+	t.Setenv("ANNOTATION_EXPERIMENTAL_REGEXP", "^# @experimental")
+	writeConfFile := func(content string) {
+		err := os.WriteFile(
+			filepath.Join(rootDirectory, ".bash-compiler"),
+			[]byte("TEMPLATES_ROOT_DIR="+rootDirectory+"\n"+content), 0o600,
+		)
+		assert.NilError(t, err)
+	}
+	service := NewCompilerPipelineService(CompilerPipelineOptions{ //nolint:exhaustruct // test
+		RootDirectory: rootDirectory,
+	})
+	defer service.unsetGlobalCustomAnnotations()
+
+	writeConfFile("ANNOTATION_AUDIT_REGEXP=^# @audit\n")
+	assert.NilError(t, service.loadConfFile())
+	assert.DeepEqual(t, []model.CustomAnnotationConfig{
+		{Name: "audit", Regexp: "^# @audit", TemplateName: ""},
+		{Name: "experimental", Regexp: "^# @experimental", TemplateName: ""},
+	}, getGlobalCustomAnnotations())
+
+	// the annotations removed from the config file are unset, the environment ones are kept
+	writeConfFile("")
+	assert.NilError(t, service.loadConfFile())
+	assert.DeepEqual(t, []model.CustomAnnotationConfig{
+		{Name: "experimental", Regexp: "^# @experimental", TemplateName: ""},
+	}, getGlobalCustomAnnotations())
+}
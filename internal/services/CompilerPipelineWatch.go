@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+	"github.com/fchastanet/bash-compiler/internal/utils/structures"
+	"github.com/fsnotify/fsnotify"
+)
+
+// delay during which file events are accumulated before recompiling,
+// editors usually generate several events for a single save
+const watchDebounceDelay = 100 * time.Millisecond
+
+const configFileName = ".bash-compiler"
+
+// binaryModelDependencies references every file and directory
+// that has been used to compile a binary model
+type binaryModelDependencies struct {
+	targetFile   string
+	files        map[string]bool
+	srcDirs      []string
+	templateDirs []string
+}
+
+func newBinaryModelDependencies(
+	binaryModelFilePath string,
+	binaryModelServiceContextData *BinaryModelServiceContextData,
+) *binaryModelDependencies {
+	dependencies := &binaryModelDependencies{
+		targetFile:   "",
+		files:        map[string]bool{filepath.Clean(binaryModelFilePath): true},
+		srcDirs:      []string{},
+		templateDirs: []string{},
+	}
+	if binaryModelServiceContextData == nil {
+		return dependencies
+	}
+	binaryModelData := binaryModelServiceContextData.binaryModelData
+	if binaryModelData != nil {
+		dependencies.targetFile = filepath.Clean(
+			structures.ExpandStringValue(binaryModelData.CompilerConfig.TargetFile),
+		)
+		dependencies.addFiles(binaryModelData.LoadedFiles)
+		dependencies.srcDirs = cleanPaths(binaryModelData.CompilerConfig.SrcDirsExpanded)
+		dependencies.templateDirs = cleanPaths(
+			structures.ExpandStringList(binaryModelData.CompilerConfig.TemplateDirs),
+		)
+	}
+	if binaryModelServiceContextData.templateContextData != nil {
+		dependencies.addFiles(binaryModelServiceContextData.templateContextData.GetIncludedFiles())
+	}
+	if binaryModelServiceContextData.compileContextData != nil {
+		dependencies.addFiles(binaryModelServiceContextData.compileContextData.GetFunctionsSrcFiles())
+	}
+	return dependencies
+}
+
+func (dependencies *binaryModelDependencies) addFiles(files []string) {
+	for _, file := range files {
+		dependencies.files[filepath.Clean(file)] = true
+	}
+}
+
+// isImpactedBy indicates if the binary needs to be recompiled
+// structuralChange is true if the file has been created, removed or renamed
+func (dependencies *binaryModelDependencies) isImpactedBy(
+	changedFile string, structuralChange bool,
+) bool {
+	if changedFile == dependencies.targetFile {
+		return false
+	}
+	if dependencies.files[changedFile] {
+		return true
+	}
+	switch filepath.Ext(changedFile) {
+	case ".gtpl":
+		// templates can be included dynamically, any template change is relevant
+		return isInDirectories(changedFile, dependencies.templateDirs)
+	case ".sh":
+		// a new function file can shadow the one previously resolved
+		return structuralChange && isInDirectories(changedFile, dependencies.srcDirs)
+	}
+	return false
+}
+
+func cleanPaths(paths []string) []string {
+	cleanedPaths := make([]string, 0, len(paths))
+	for _, path := range paths {
+		cleanedPaths = append(cleanedPaths, filepath.Clean(path))
+	}
+	return cleanedPaths
+}
+
+func isInDirectories(file string, directories []string) bool {
+	for _, directory := range directories {
+		if strings.HasPrefix(file, directory+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// computeImpactedBinaryModels returns the sorted list of binary model files
+// impacted by the changed files
+func computeImpactedBinaryModels(
+	dependenciesMap map[string]*binaryModelDependencies,
+	changedFiles map[string]bool,
+) []string {
+	impactedBinaryModels := []string{}
+	for binaryModelFilePath, dependencies := range dependenciesMap {
+		for changedFile, structuralChange := range changedFiles {
+			if dependencies.isImpactedBy(changedFile, structuralChange) {
+				impactedBinaryModels = append(impactedBinaryModels, binaryModelFilePath)
+				break
+			}
+		}
+	}
+	sort.Strings(impactedBinaryModels)
+	return impactedBinaryModels
+}
+
+// Watch compiles all the binaries then waits for file changes
+// and recompiles only the impacted binaries until ctx is canceled
+func (service *CompilerPipelineService) Watch(ctx context.Context) error {
+	autoDiscoverYamlFiles := len(service.options.YamlFiles) == 0
+	binaryModelFilePaths, err := service.getBinaryModelFilePaths()
+	if err != nil {
+		return err
+	}
+	service.compileBinaryModels(binaryModelFilePaths)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	watchedDirectories := map[string]bool{}
+	service.watchDirectories(watcher, watchedDirectories)
+	slog.Info("Watching for changes", "directoriesCount", len(watchedDirectories))
+
+	changedFiles := map[string]bool{}
+	timer := time.NewTimer(watchDebounceDelay)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			slog.Debug("File event", logger.LogFieldFilePath, event.Name, "operation", event.Op.String())
+			structuralChange := event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) ||
+				event.Has(fsnotify.Rename)
+			changedFile := filepath.Clean(event.Name)
+			if structuralChange && watchedDirectories[changedFile] {
+				// removed directory, it will be watched again if recreated
+				delete(watchedDirectories, changedFile)
+			}
+			changedFiles[changedFile] = changedFiles[changedFile] || structuralChange
+			timer.Reset(watchDebounceDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Error("Watcher error", logger.LogFieldErr, err)
+		case <-timer.C:
+			service.recompileChangedFiles(changedFiles, autoDiscoverYamlFiles)
+			changedFiles = map[string]bool{}
+			// new directories could have been created
+			service.watchDirectories(watcher, watchedDirectories)
+		}
+	}
+}
+
+func (service *CompilerPipelineService) recompileChangedFiles(
+	changedFiles map[string]bool,
+	autoDiscoverYamlFiles bool,
+) {
+	configFile := filepath.Clean(filepath.Join(service.options.RootDirectory, configFileName))
+	configChanged := false
+	if _, exists := changedFiles[configFile]; exists {
+		slog.Info("Config file changed, reloading", logger.LogFieldFilePath, configFile)
+		err := service.loadConfFile()
+		if err != nil {
+			slog.Error("Config file reload failed", logger.LogFieldErr, err)
+			return
+		}
+		// the custom annotations declared in the config file could have changed
+		service.initBinaryModelService()
+		configChanged = true
+	}
+
+	if autoDiscoverYamlFiles {
+		// allows to discover new binary files
+		service.options.YamlFiles = nil
+	}
+	binaryModelFilePaths, err := service.getBinaryModelFilePaths()
+	if err != nil {
+		slog.Error("Binary model files listing failed", logger.LogFieldErr, err)
+		return
+	}
+	service.forgetRemovedBinaryModels(binaryModelFilePaths)
+
+	var impactedBinaryModels []string
+	if configChanged {
+		impactedBinaryModels = binaryModelFilePaths
+	} else {
+		impactedBinaryModels = computeImpactedBinaryModels(service.binaryModelDependencies, changedFiles)
+		for _, binaryModelFilePath := range binaryModelFilePaths {
+			_, known := service.binaryModelDependencies[binaryModelFilePath]
+			if !known && !slices.Contains(impactedBinaryModels, binaryModelFilePath) {
+				impactedBinaryModels = append(impactedBinaryModels, binaryModelFilePath)
+			}
+		}
+	}
+
+	service.compileBinaryModels(impactedBinaryModels)
+}
+
+// compileBinaryModels compiles each binary model, compilation errors are only
+// logged as they should not stop the watch
+func (service *CompilerPipelineService) compileBinaryModels(binaryModelFilePaths []string) {
+	for _, binaryModelFilePath := range binaryModelFilePaths {
+		slog.Info("Compiling", logger.LogFieldFilePath, binaryModelFilePath)
+		_, err := service.processBinaryModel(binaryModelFilePath)
+		if err != nil {
+			slog.Error("Compilation failed", logger.LogFieldFilePath, binaryModelFilePath, logger.LogFieldErr, err)
+		}
+	}
+}
+
+func (service *CompilerPipelineService) forgetRemovedBinaryModels(binaryModelFilePaths []string) {
+	for binaryModelFilePath := range service.binaryModelDependencies {
+		if !slices.Contains(binaryModelFilePaths, binaryModelFilePath) {
+			delete(service.binaryModelDependencies, binaryModelFilePath)
+		}
+	}
+}
+
+// watchDirectories adds to the watcher the root directory, the src and template
+// directories and the directories of each dependency file, recursively
+func (service *CompilerPipelineService) watchDirectories(
+	watcher *fsnotify.Watcher,
+	watchedDirectories map[string]bool,
+) {
+	recursiveDirectories := []string{service.options.RootDirectory}
+	directories := []string{}
+	for _, dependencies := range service.binaryModelDependencies {
+		recursiveDirectories = append(recursiveDirectories, dependencies.srcDirs...)
+		recursiveDirectories = append(recursiveDirectories, dependencies.templateDirs...)
+		for file := range dependencies.files {
+			directories = append(directories, filepath.Dir(file))
+		}
+	}
+	for _, directory := range recursiveDirectories {
+		err := filepath.WalkDir(directory, func(path string, dirEntry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if !dirEntry.IsDir() {
+				return nil
+			}
+			if path != directory && strings.HasPrefix(dirEntry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			addWatchedDirectory(watcher, watchedDirectories, path)
+			return nil
+		})
+		if err != nil {
+			slog.Warn("Unable to watch directory", logger.LogFieldDirPath, directory, logger.LogFieldErr, err)
+		}
+	}
+	for _, directory := range directories {
+		addWatchedDirectory(watcher, watchedDirectories, directory)
+	}
+}
+
+func addWatchedDirectory(
+	watcher *fsnotify.Watcher,
+	watchedDirectories map[string]bool,
+	directory string,
+) {
+	directory = filepath.Clean(directory)
+	if watchedDirectories[directory] {
+		return
+	}
+	err := watcher.Add(directory)
+	if err != nil {
+		slog.Warn("Unable to watch directory", logger.LogFieldDirPath, directory, logger.LogFieldErr, err)
+		return
+	}
+	watchedDirectories[directory] = true
+}
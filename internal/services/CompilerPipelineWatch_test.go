@@ -0,0 +1,150 @@
+package services
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func getDependenciesMap() map[string]*binaryModelDependencies {
+	return map[string]*binaryModelDependencies{
+		"/project/bin1-binary.yaml": {
+			targetFile: "/project/bin/bin1",
+			files: map[string]bool{
+				"/project/bin1-binary.yaml":    true,
+				"/project/defaults.yaml":       true,
+				"/project/src/Log/info.sh":     true,
+				"/project/templates/bin1.gtpl": true,
+			},
+			srcDirs:      []string{"/project/src"},
+			templateDirs: []string{"/project/templates"},
+		},
+		"/project/bin2-binary.yaml": {
+			targetFile: "/project/bin/bin2",
+			files: map[string]bool{
+				"/project/bin2-binary.yaml": true,
+				"/project/defaults.yaml":    true,
+				"/project/src/Git/clone.sh": true,
+			},
+			srcDirs:      []string{"/project/src"},
+			templateDirs: []string{"/project/otherTemplates"},
+		},
+	}
+}
+
+func TestComputeImpactedBinaryModels(t *testing.T) {
+	dependenciesMap := getDependenciesMap()
+	tests := []struct {
+		name         string
+		changedFiles map[string]bool
+		expected     []string
+	}{
+		{
+			name:         "no change",
+			changedFiles: map[string]bool{},
+			expected:     []string{},
+		},
+		{
+			name:         "shared yaml file",
+			changedFiles: map[string]bool{"/project/defaults.yaml": false},
+			expected:     []string{"/project/bin1-binary.yaml", "/project/bin2-binary.yaml"},
+		},
+		{
+			name:         "function file modified",
+			changedFiles: map[string]bool{"/project/src/Git/clone.sh": false},
+			expected:     []string{"/project/bin2-binary.yaml"},
+		},
+		{
+			name:         "unused function file modified",
+			changedFiles: map[string]bool{"/project/src/Git/pull.sh": false},
+			expected:     []string{},
+		},
+		{
+			name:         "function file created",
+			changedFiles: map[string]bool{"/project/src/Git/pull.sh": true},
+			expected:     []string{"/project/bin1-binary.yaml", "/project/bin2-binary.yaml"},
+		},
+		{
+			name:         "template not included modified",
+			changedFiles: map[string]bool{"/project/templates/other.gtpl": false},
+			expected:     []string{"/project/bin1-binary.yaml"},
+		},
+		{
+			name:         "target file modified",
+			changedFiles: map[string]bool{"/project/bin/bin1": false},
+			expected:     []string{},
+		},
+		{
+			name:         "file outside of any directory",
+			changedFiles: map[string]bool{"/other/src/Git/clone.sh": true},
+			expected:     []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impactedBinaryModels := computeImpactedBinaryModels(dependenciesMap, tt.changedFiles)
+			assert.DeepEqual(t, tt.expected, impactedBinaryModels)
+		})
+	}
+}
+
+func TestNewBinaryModelDependenciesWithoutContext(t *testing.T) {
+	dependencies := newBinaryModelDependencies("/project/./bin1-binary.yaml", nil)
+	assert.DeepEqual(t, map[string]bool{"/project/bin1-binary.yaml": true}, dependencies.files)
+	assert.Assert(t, dependencies.isImpactedBy("/project/bin1-binary.yaml", false))
+}
+
+// TestRecompileChangedFilesConfigChanged ensures the custom annotations of the config file are reloaded
+func TestRecompileChangedFilesConfigChanged(t *testing.T) {
+	rootDirectory := t.TempDir()
+	_, err := NewProjectScaffoldService(rootDirectory, "-binary.yaml", "").Init("hello")
+	assert.NilError(t, err)
+	binaryModelFile := filepath.Join(rootDirectory, "hello-binary.yaml")
+	content, err := os.ReadFile(binaryModelFile)
+	assert.NilError(t, err)
+	content = []byte(strings.Replace(
+		string(content), "compilerConfig:\n", "compilerConfig:\n  annotationsConfig:\n    auditTemplateName: audit\n", 1,
+	))
+	assert.NilError(t, os.WriteFile(binaryModelFile, content, 0o600))
+	assert.NilError(t, os.WriteFile(
+		filepath.Join(rootDirectory, "templates", "audit.gtpl"),
+		[]byte(`{{- define "audit" -}}echo "audited {{ index .Data.values 0 }}"{{- end }}`), 0o600,
+	))
+	assert.NilError(t, os.WriteFile(
+		filepath.Join(rootDirectory, "src", "_binaries", "hello", "main.sh"),
+		[]byte("#!/usr/bin/env bash\n# @audit main\nSample::greet \"world\"\n"), 0o600,
+	))
+	defaultTemplatesDir, err := filepath.Abs(filepath.Join("..", "..", "cmd", "bash-compiler", "defaultTemplates"))
+	assert.NilError(t, err)
+	t.Setenv("DEFAULT_TEMPLATE_FOLDER", defaultTemplatesDir)
+	t.Setenv("ROOT_DIR", "")
+	t.Setenv("TEMPLATES_ROOT_DIR", "")
+
+	service := NewCompilerPipelineService(CompilerPipelineOptions{ //nolint:exhaustruct // test
+		RootDirectory:        rootDirectory,
+		BinaryFilesExtension: "-binary.yaml",
+		Jobs:                 1,
+	})
+	defer service.unsetGlobalCustomAnnotations()
+	assert.NilError(t, service.Init())
+	assert.NilError(t, service.ProcessPipeline())
+	binaryFile := filepath.Join(rootDirectory, "bin", "hello")
+	output, err := exec.Command("bash", binaryFile).CombinedOutput()
+	assert.NilError(t, err, string(output))
+	assert.Equal(t, "Hello world!\n", string(output))
+
+	configFile := filepath.Join(rootDirectory, configFileName)
+	configContent, err := os.ReadFile(configFile)
+	assert.NilError(t, err)
+	configContent = append(configContent, "ANNOTATION_AUDIT_REGEXP=^# @audit(?P<value>.*)\n"...)
+	configContent = append(configContent, "ANNOTATION_AUDIT_TEMPLATE_NAME=auditTemplateName\n"...)
+	assert.NilError(t, os.WriteFile(configFile, configContent, 0o600))
+	service.recompileChangedFiles(map[string]bool{configFile: false}, false)
+	output, err = exec.Command("bash", binaryFile).CombinedOutput()
+	assert.NilError(t, err, string(output))
+	assert.Equal(t, "audited main\nHello world!\n", string(output))
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/fchastanet/bash-compiler/internal/compiler"
+)
+
+type functionNotResolvedError struct {
+	error
+	FunctionName string
+}
+
+func (err *functionNotResolvedError) Error() string {
+	return "function " + err.FunctionName + " not found in srcDirs of any binary model"
+}
+
+// Which resolves the source file of the function for each binary model
+func (service *CompilerPipelineService) Which(functionName string) ([]*compiler.FunctionResolution, error) {
+	binaryModelFilePaths, err := service.getBinaryModelFilePaths()
+	if err != nil {
+		return nil, err
+	}
+	resolutions := make([]*compiler.FunctionResolution, 0, len(binaryModelFilePaths))
+	for _, binaryModelFilePath := range binaryModelFilePaths {
+		resolution, err := service.whichBinaryModel(binaryModelFilePath, functionName)
+		if err != nil {
+			return nil, err
+		}
+		resolutions = append(resolutions, resolution)
+	}
+	return resolutions, nil
+}
+
+func (service *CompilerPipelineService) whichBinaryModel(
+	binaryModelFilePath string, functionName string,
+) (*compiler.FunctionResolution, error) {
+	defaultLogger := slog.Default()
+	slog.SetDefault(defaultLogger.With("binaryModelFilePath", binaryModelFilePath))
+	defer slog.SetDefault(defaultLogger)
+
+	binaryModelServiceContextData, err := service.binaryModelService.Init(
+		service.options.IntermediateFilesDir,
+		binaryModelFilePath,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return service.binaryModelService.Which(binaryModelServiceContextData, functionName), nil
+}
+
+// WriteFunctionResolutions writes for each binary model the file used for the function
+// followed by the shadowed copies, an error is returned if the function is never found
+func WriteFunctionResolutions(output io.Writer, resolutions []*compiler.FunctionResolution) error {
+	found := false
+	for _, resolution := range resolutions {
+		var err error
+		if resolution.SrcFile == "" {
+			_, err = fmt.Fprintf(
+				output, "%s: not found in srcDirs %v\n", resolution.BinaryModelFilePath, resolution.SrcDirs,
+			)
+		} else {
+			found = true
+			_, err = fmt.Fprintf(output, "%s: %s\n", resolution.BinaryModelFilePath, resolution.SrcFile)
+		}
+		if err != nil {
+			return err
+		}
+		for _, shadowedSrcFile := range resolution.ShadowedSrcFiles {
+			_, err = fmt.Fprintf(output, "  shadowed: %s\n", shadowedSrcFile)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !found && len(resolutions) > 0 {
+		return &functionNotResolvedError{nil, resolutions[0].FunctionName}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fchastanet/bash-compiler/internal/compiler"
+	"gotest.tools/v3/assert"
+)
+
+func TestWriteFunctionResolutions(t *testing.T) {
+	resolutions := []*compiler.FunctionResolution{
+		{
+			FunctionName:        "Log::info",
+			BinaryModelFilePath: "a-binary.yaml",
+			SrcFile:             "/project/src/Log/info.sh",
+			ShadowedSrcFiles:    []string{"/vendor/src/Log/info.sh"},
+			SrcDirs:             []string{"/project/src", "/vendor/src"},
+		},
+		{
+			FunctionName:        "Log::info",
+			BinaryModelFilePath: "b-binary.yaml",
+			SrcFile:             "",
+			ShadowedSrcFiles:    []string{},
+			SrcDirs:             []string{"/other/src"},
+		},
+	}
+	t.Run("found", func(t *testing.T) {
+		var output bytes.Buffer
+		err := WriteFunctionResolutions(&output, resolutions)
+		assert.NilError(t, err)
+		assert.Equal(t,
+			"a-binary.yaml: /project/src/Log/info.sh\n"+
+				"  shadowed: /vendor/src/Log/info.sh\n"+
+				"b-binary.yaml: not found in srcDirs [/other/src]\n",
+			output.String(),
+		)
+	})
+	t.Run("not found", func(t *testing.T) {
+		var output bytes.Buffer
+		err := WriteFunctionResolutions(&output, resolutions[1:])
+		assert.Error(t, err, "function Log::info not found in srcDirs of any binary model")
+	})
+}
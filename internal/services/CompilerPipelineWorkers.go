@@ -0,0 +1,101 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+// WorkerCommandFactory creates the command compiling a single binary model
+// in a separate process, each binary model compilation has then its own
+// environment variables and logger.
+// The process is expected to write the results as json on stdout (see ProcessWorker)
+type WorkerCommandFactory func(binaryModelFilePath string) *exec.Cmd
+
+type binaryModelCompilationError struct {
+	error
+	BinaryModelFilePath string
+	InnerError          error
+}
+
+func (err *binaryModelCompilationError) Error() string {
+	return fmt.Sprintf("compilation of %s failed : %v", err.BinaryModelFilePath, err.InnerError)
+}
+
+type workerResult struct {
+	output  []byte
+	results []*BinaryModelResult
+	err     error
+}
+
+// getJobsCount returns the number of binary models that can be compiled in parallel
+func getJobsCount(jobs int, binaryModelsCount int) int {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	return max(1, min(jobs, binaryModelsCount))
+}
+
+// processBinaryModelsInWorkers compiles each binary model in a separate process.
+// Outputs of each worker are written to output in the order of the binary models
+// and the first error in this order is returned along with the results of each worker
+func (service *CompilerPipelineService) processBinaryModelsInWorkers(
+	binaryModelFilePaths []string,
+	jobsCount int,
+	output io.Writer,
+) ([]*BinaryModelResult, error) {
+	slog.Info("Compiling binary models in parallel", "binaryModelsCount", len(binaryModelFilePaths), "jobs", jobsCount)
+	workerResults := make([]workerResult, len(binaryModelFilePaths))
+	indexes := make(chan int)
+	var waitGroup sync.WaitGroup
+	for range jobsCount {
+		waitGroup.Go(func() {
+			for index := range indexes {
+				workerResults[index] = service.runWorker(binaryModelFilePaths[index])
+			}
+		})
+	}
+	for index := range binaryModelFilePaths {
+		indexes <- index
+	}
+	close(indexes)
+	waitGroup.Wait()
+
+	var firstError error
+	results := make([]*BinaryModelResult, 0, len(binaryModelFilePaths))
+	for index, workerResult := range workerResults {
+		_, err := output.Write(workerResult.output)
+		if logger.FancyHandleError(err) {
+			return nil, err
+		}
+		if workerResult.err != nil && firstError == nil {
+			firstError = &binaryModelCompilationError{nil, binaryModelFilePaths[index], workerResult.err}
+		}
+		results = append(results, workerResult.results...)
+	}
+	return results, firstError
+}
+
+func (service *CompilerPipelineService) runWorker(binaryModelFilePath string) workerResult {
+	var output bytes.Buffer
+	var stdout bytes.Buffer
+	cmd := service.options.WorkerCommandFactory(binaryModelFilePath)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &output
+	err := cmd.Run()
+	results := []*BinaryModelResult{}
+	if err != nil {
+		// a failing worker still reports the results computed so far
+		_ = json.Unmarshal(stdout.Bytes(), &results)
+		return workerResult{output: output.Bytes(), results: results, err: err}
+	}
+	err = json.Unmarshal(stdout.Bytes(), &results)
+	return workerResult{output: output.Bytes(), results: results, err: err}
+}
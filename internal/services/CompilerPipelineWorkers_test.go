@@ -0,0 +1,56 @@
+package services
+
+import (
+	"bytes"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestGetJobsCount(t *testing.T) {
+	assert.Equal(t, 1, getJobsCount(1, 10))
+	assert.Equal(t, 4, getJobsCount(4, 10))
+	assert.Equal(t, 2, getJobsCount(4, 2))
+	assert.Equal(t, 1, getJobsCount(4, 0))
+	assert.Equal(t, min(runtime.NumCPU(), 100), getJobsCount(0, 100))
+}
+
+func TestProcessBinaryModelsInWorkers(t *testing.T) {
+	service := NewCompilerPipelineService(CompilerPipelineOptions{ //nolint:exhaustruct // test
+		Jobs: 3,
+		WorkerCommandFactory: func(binaryModelFilePath string) *exec.Cmd {
+			// the slower the first ones, to ensure output order is kept
+			return exec.Command(
+				"sh", "-c", `case "$1" in
+					a) sleep 0.2; echo "a done" >&2; echo '[{"binaryModelFilePath":"a","targetFile":"bin/a","stale":true}]' ;;
+					b) sleep 0.1; echo "b failed" >&2; echo '[{"binaryModelFilePath":"b","error":"boom"}]'; exit 1 ;;
+					*) echo "$1 failed" >&2; exit 2 ;;
+				esac`, "sh", binaryModelFilePath,
+			)
+		},
+	})
+	t.Run("deterministic output and first error", func(t *testing.T) {
+		var output bytes.Buffer
+		results, err := service.processBinaryModelsInWorkers([]string{"a", "b", "c"}, 3, &output)
+		assert.Equal(t, "a done\nb failed\nc failed\n", output.String())
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, "a", results[0].BinaryModelFilePath)
+		assert.Equal(t, "b", results[1].BinaryModelFilePath)
+		assert.Equal(t, "boom", results[1].Error)
+		assert.ErrorContains(t, err, "compilation of b failed : exit status 1")
+	})
+	t.Run("no error", func(t *testing.T) {
+		var output bytes.Buffer
+		results, err := service.processBinaryModelsInWorkers([]string{"a", "a"}, 2, &output)
+		assert.NilError(t, err)
+		assert.Equal(t, "a done\na done\n", output.String())
+		assert.Equal(t, 2, len(results))
+		for _, result := range results {
+			assert.Equal(t, "a", result.BinaryModelFilePath)
+			assert.Equal(t, "bin/a", result.TargetFile)
+			assert.Assert(t, result.Stale)
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/fchastanet/bash-compiler/internal/utils/files"
+	"github.com/fchastanet/bash-compiler/internal/utils/logger"
+)
+
+//go:embed scaffoldTemplates
+var scaffoldTemplatesFs embed.FS
+
+const (
+	sampleFunctionName = "Sample::greet"
+	templatesDirName   = "templates"
+	// frameworkFallbackDirName is the srcDir of the minimal implementations of the framework functions
+	// used by the default templates, generated if no framework src directory is provided
+	frameworkFallbackDirName = "framework"
+)
+
+// frameworkFallbackFunctions are the framework functions used by the default templates
+var frameworkFallbackFunctions = []string{
+	"Array::wrap2",
+	"Log::displayDebug",
+	"Log::displayError",
+	"Log::displayInfo",
+}
+
+var binaryNameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+type invalidBinaryNameError struct {
+	error
+	BinaryName string
+}
+
+func (err *invalidBinaryNameError) Error() string {
+	return fmt.Sprintf(
+		"invalid binary name '%s', it should start with a letter followed by letters, digits, - or _",
+		err.BinaryName,
+	)
+}
+
+type fileAlreadyExistsError struct {
+	error
+	FilePath string
+}
+
+func (err *fileAlreadyExistsError) Error() string {
+	return "file already exists: " + err.FilePath
+}
+
+type ProjectScaffoldService struct {
+	rootDirectory        string
+	binaryFilesExtension string
+	frameworkSrcDir      string
+}
+
+type scaffoldFile struct {
+	filePath     string
+	templateName string
+	// skipIfExists allows files shared by several binaries to be kept instead of failing
+	skipIfExists bool
+}
+
+type scaffoldData struct {
+	BinaryName           string
+	FunctionName         string
+	MainFile             string
+	FrameworkSrcDir      string
+	FrameworkFallbackDir string
+	SampleFunctionName   string
+	SampleFunctionPath   string
+}
+
+// NewProjectScaffoldService creates the service generating starter files,
+// frameworkSrcDir is added to the srcDirs of the generated binary models if provided,
+// otherwise minimal implementations of the framework functions used by the default templates are generated
+func NewProjectScaffoldService(
+	rootDirectory string,
+	binaryFilesExtension string,
+	frameworkSrcDir string,
+) *ProjectScaffoldService {
+	return &ProjectScaffoldService{
+		rootDirectory:        rootDirectory,
+		binaryFilesExtension: binaryFilesExtension,
+		frameworkSrcDir:      frameworkSrcDir,
+	}
+}
+
+// Init creates a new project with a .bash-compiler file, a templates directory,
+// a sample function and a binary model using it.
+// Existing files are never overwritten.
+func (service *ProjectScaffoldService) Init(binaryName string) ([]string, error) {
+	data, err := service.newScaffoldData(binaryName)
+	if err != nil {
+		return nil, err
+	}
+	data.SampleFunctionName = sampleFunctionName
+	data.SampleFunctionPath = strings.ReplaceAll(sampleFunctionName, "::", "/") + ".sh"
+	scaffoldFiles := append([]scaffoldFile{
+		{configFileName, "bash-compiler.gtpl", false},
+		{filepath.Join(templatesDirName, ".gitkeep"), "", false},
+		// target directory of the binaries, not created by the compilation
+		{filepath.Join("bin", ".gitkeep"), "", false},
+		{filepath.Join("src", data.SampleFunctionPath), "function.sh.gtpl", false},
+	}, service.getBinaryScaffoldFiles(data)...)
+	return service.createFiles(scaffoldFiles, data)
+}
+
+// NewBinary adds a binary model and its main file to an existing project
+func (service *ProjectScaffoldService) NewBinary(binaryName string) ([]string, error) {
+	data, err := service.newScaffoldData(binaryName)
+	if err != nil {
+		return nil, err
+	}
+	return service.createFiles(service.getBinaryScaffoldFiles(data), data)
+}
+
+func (service *ProjectScaffoldService) newScaffoldData(binaryName string) (*scaffoldData, error) {
+	if !binaryNameRegexp.MatchString(binaryName) {
+		return nil, &invalidBinaryNameError{nil, binaryName}
+	}
+	return &scaffoldData{
+		BinaryName:           binaryName,
+		FunctionName:         toCamelCase(binaryName) + "Command",
+		MainFile:             filepath.Join("src", "_binaries", binaryName, "main.sh"),
+		FrameworkSrcDir:      service.frameworkSrcDir,
+		FrameworkFallbackDir: frameworkFallbackDirName,
+		SampleFunctionName:   "",
+		SampleFunctionPath:   "",
+	}, nil
+}
+
+func (service *ProjectScaffoldService) getBinaryScaffoldFiles(data *scaffoldData) []scaffoldFile {
+	scaffoldFiles := []scaffoldFile{
+		{data.BinaryName + service.binaryFilesExtension, "binary.yaml.gtpl", false},
+		{data.MainFile, "main.sh.gtpl", false},
+	}
+	if data.FrameworkSrcDir != "" {
+		return scaffoldFiles
+	}
+	// the binary model references the fallback directory, kept if already generated
+	for _, functionName := range frameworkFallbackFunctions {
+		functionPath := strings.ReplaceAll(functionName, "::", "/") + ".sh"
+		scaffoldFiles = append(scaffoldFiles, scaffoldFile{
+			filepath.Join(data.FrameworkFallbackDir, functionPath),
+			path.Join(frameworkFallbackDirName, functionPath+".gtpl"),
+			true,
+		})
+	}
+	return scaffoldFiles
+}
+
+// createFiles renders all the files before writing them
+// so that nothing is written if one of them already exists
+func (service *ProjectScaffoldService) createFiles(
+	scaffoldFiles []scaffoldFile, data *scaffoldData,
+) ([]string, error) {
+	filePaths := make([]string, 0, len(scaffoldFiles))
+	contents := make([][]byte, 0, len(scaffoldFiles))
+	for _, scaffoldFile := range scaffoldFiles {
+		filePath := filepath.Join(service.rootDirectory, scaffoldFile.filePath)
+		_, err := os.Stat(filePath)
+		if err == nil && scaffoldFile.skipIfExists {
+			slog.Info("Kept existing file", logger.LogFieldFilePath, filePath)
+			continue
+		}
+		if err == nil {
+			return nil, &fileAlreadyExistsError{nil, filePath}
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		content, err := renderScaffoldTemplate(scaffoldFile.templateName, data)
+		if err != nil {
+			return nil, err
+		}
+		filePaths = append(filePaths, filePath)
+		contents = append(contents, content)
+	}
+
+	createdFiles := make([]string, 0, len(filePaths))
+	for index, filePath := range filePaths {
+		err := os.MkdirAll(filepath.Dir(filePath), files.AllReadExecutePerm)
+		if logger.FancyHandleError(err) {
+			return createdFiles, err
+		}
+		err = os.WriteFile(filePath, contents[index], files.AllReadPerm)
+		if logger.FancyHandleError(err) {
+			return createdFiles, err
+		}
+		slog.Info("Created", logger.LogFieldFilePath, filePath)
+		createdFiles = append(createdFiles, filePath)
+	}
+	return createdFiles, nil
+}
+
+func renderScaffoldTemplate(templateName string, data *scaffoldData) ([]byte, error) {
+	if templateName == "" {
+		return []byte{}, nil
+	}
+	scaffoldTemplate, err := template.ParseFS(scaffoldTemplatesFs, "scaffoldTemplates/"+templateName)
+	if err != nil {
+		return nil, err
+	}
+	var content bytes.Buffer
+	err = scaffoldTemplate.Execute(&content, data)
+	if err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
+
+// toCamelCase converts my-binary_name to myBinaryName
+func toCamelCase(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_'
+	})
+	var camelCase strings.Builder
+	for index, word := range words {
+		if index == 0 {
+			camelCase.WriteString(word)
+			continue
+		}
+		camelCase.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return camelCase.String()
+}
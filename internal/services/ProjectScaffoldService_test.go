@@ -0,0 +1,176 @@
+package services
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestProjectScaffoldInit(t *testing.T) {
+	rootDirectory := t.TempDir()
+	service := NewProjectScaffoldService(rootDirectory, "-binary.yaml", "${ROOT_DIR}/vendor/src")
+	createdFiles, err := service.Init("my-tool")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{
+		filepath.Join(rootDirectory, ".bash-compiler"),
+		filepath.Join(rootDirectory, "templates", ".gitkeep"),
+		filepath.Join(rootDirectory, "bin", ".gitkeep"),
+		filepath.Join(rootDirectory, "src", "Sample", "greet.sh"),
+		filepath.Join(rootDirectory, "my-tool-binary.yaml"),
+		filepath.Join(rootDirectory, "src", "_binaries", "my-tool", "main.sh"),
+	}, createdFiles)
+
+	content, err := os.ReadFile(filepath.Join(rootDirectory, "my-tool-binary.yaml"))
+	assert.NilError(t, err)
+	assert.Assert(t, is.Contains(string(content), "      functionName: myToolCommand"))
+	assert.Assert(t, is.Contains(string(content), "    - ${ROOT_DIR}/vendor/src"))
+	content, err = os.ReadFile(filepath.Join(rootDirectory, "src", "_binaries", "my-tool", "main.sh"))
+	assert.NilError(t, err)
+	assert.Assert(t, is.Contains(string(content), `Sample::greet "world"`))
+
+	t.Run("existing project", func(t *testing.T) {
+		_, err := service.Init("other")
+		assert.Error(t, err, "file already exists: "+filepath.Join(rootDirectory, ".bash-compiler"))
+		_, err = os.Stat(filepath.Join(rootDirectory, "other-binary.yaml"))
+		assert.Assert(t, os.IsNotExist(err))
+	})
+}
+
+func TestProjectScaffoldNewBinary(t *testing.T) {
+	rootDirectory := t.TempDir()
+	service := NewProjectScaffoldService(rootDirectory, "-binary.yaml", "")
+	createdFiles, err := service.NewBinary("other_tool")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{
+		filepath.Join(rootDirectory, "other_tool-binary.yaml"),
+		filepath.Join(rootDirectory, "src", "_binaries", "other_tool", "main.sh"),
+		filepath.Join(rootDirectory, "framework", "Array", "wrap2.sh"),
+		filepath.Join(rootDirectory, "framework", "Log", "displayDebug.sh"),
+		filepath.Join(rootDirectory, "framework", "Log", "displayError.sh"),
+		filepath.Join(rootDirectory, "framework", "Log", "displayInfo.sh"),
+	}, createdFiles)
+	content, err := os.ReadFile(filepath.Join(rootDirectory, "src", "_binaries", "other_tool", "main.sh"))
+	assert.NilError(t, err)
+	assert.Assert(t, is.Contains(string(content), `echo "other_tool"`))
+	content, err = os.ReadFile(filepath.Join(rootDirectory, "other_tool-binary.yaml"))
+	assert.NilError(t, err)
+	assert.Assert(t, is.Contains(string(content), "    - ${ROOT_DIR}/framework\n"))
+
+	_, err = service.NewBinary("other_tool")
+	assert.ErrorContains(t, err, "file already exists")
+	// framework fallback functions already generated are kept
+	createdFiles, err = service.NewBinary("third")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{
+		filepath.Join(rootDirectory, "third-binary.yaml"),
+		filepath.Join(rootDirectory, "src", "_binaries", "third", "main.sh"),
+	}, createdFiles)
+	_, err = service.NewBinary("1tool")
+	assert.Error(t, err, "invalid binary name '1tool', it should start with a letter followed by letters, digits, - or _")
+}
+
+// TestProjectScaffoldCompiles ensures the generated project compiles and runs without bash-tools-framework
+func TestProjectScaffoldCompiles(t *testing.T) {
+	rootDirectory := t.TempDir()
+	_, err := NewProjectScaffoldService(rootDirectory, "-binary.yaml", "").Init("hello")
+	assert.NilError(t, err)
+	defaultTemplatesDir, err := filepath.Abs(filepath.Join("..", "..", "cmd", "bash-compiler", "defaultTemplates"))
+	assert.NilError(t, err)
+	t.Setenv("DEFAULT_TEMPLATE_FOLDER", defaultTemplatesDir)
+	// set by the service, restored at the end of the test
+	t.Setenv("ROOT_DIR", "")
+	t.Setenv("TEMPLATES_ROOT_DIR", "")
+
+	service := NewCompilerPipelineService(CompilerPipelineOptions{ //nolint:exhaustruct // test
+		RootDirectory:        rootDirectory,
+		BinaryFilesExtension: "-binary.yaml",
+		Jobs:                 1,
+	})
+	assert.NilError(t, service.Init())
+	assert.NilError(t, service.ProcessPipeline())
+
+	binaryFile := filepath.Join(rootDirectory, "bin", "hello")
+	output, err := exec.Command("bash", binaryFile).CombinedOutput()
+	assert.NilError(t, err, string(output))
+	assert.Equal(t, "Hello world!\n", string(output))
+	output, err = exec.Command("bash", binaryFile, "--unknown").CombinedOutput()
+	assert.ErrorContains(t, err, "exit status 1")
+	assert.Assert(t, is.Contains(string(output), "ERROR   - Command hello - Invalid option --unknown"))
+}
+
+// TestRequireEnvCheckSkippedForHelp ensures the required environment variables are checked
+// after the options parsing, so the help can be displayed without them
+func TestRequireEnvCheckSkippedForHelp(t *testing.T) {
+	rootDirectory := t.TempDir()
+	_, err := NewProjectScaffoldService(rootDirectory, "-binary.yaml", "").Init("hello")
+	assert.NilError(t, err)
+	binaryModelFile := filepath.Join(rootDirectory, "hello-binary.yaml")
+	content, err := os.ReadFile(binaryModelFile)
+	assert.NilError(t, err)
+	content = []byte(strings.Replace(string(content), "      mainFile:", `      optionGroups:
+        default:
+          title: "OPTIONS:"
+      options:
+        - variableName: optionHelp
+          functionName: optionHelpFunction
+          type: Boolean
+          alts: [--help, -h]
+          help: displays this help
+          min: 0
+          max: 1
+          onValue: 1
+          offValue: 0
+          defaultValue: 0
+          group: default
+          callbacks: [Sample::help]
+      mainFile:`, 1))
+	assert.NilError(t, os.WriteFile(binaryModelFile, content, 0o600))
+	assert.NilError(t, os.WriteFile(
+		filepath.Join(rootDirectory, "src", "Sample", "help.sh"),
+		[]byte("#!/usr/bin/env bash\n\nSample::help() {\n  echo \"help displayed\"\n  exit 0\n}\n"), 0o600,
+	))
+	assert.NilError(t, os.WriteFile(
+		filepath.Join(rootDirectory, "src", "_binaries", "hello", "main.sh"),
+		[]byte("#!/usr/bin/env bash\n# @requireEnv HELLO_NAME\nSample::greet \"${HELLO_NAME}\"\n"), 0o600,
+	))
+	defaultTemplatesDir, err := filepath.Abs(filepath.Join("..", "..", "cmd", "bash-compiler", "defaultTemplates"))
+	assert.NilError(t, err)
+	t.Setenv("DEFAULT_TEMPLATE_FOLDER", defaultTemplatesDir)
+	t.Setenv("ROOT_DIR", "")
+	t.Setenv("TEMPLATES_ROOT_DIR", "")
+	t.Setenv("HELLO_NAME", "")
+
+	service := NewCompilerPipelineService(CompilerPipelineOptions{ //nolint:exhaustruct // test
+		RootDirectory:        rootDirectory,
+		BinaryFilesExtension: "-binary.yaml",
+		Jobs:                 1,
+	})
+	assert.NilError(t, service.Init())
+	assert.NilError(t, service.ProcessPipeline())
+
+	binaryFile := filepath.Join(rootDirectory, "bin", "hello")
+	output, err := exec.Command("bash", binaryFile, "--help").CombinedOutput()
+	assert.NilError(t, err, string(output))
+	assert.Equal(t, "help displayed\n", string(output))
+	output, err = exec.Command("bash", binaryFile).CombinedOutput()
+	assert.ErrorContains(t, err, "exit status 1")
+	assert.Equal(t, "Missing required environment variable: HELLO_NAME (required by main)\n", string(output))
+	// sourcing the binary does not check the variables
+	sourcingFile := filepath.Join(rootDirectory, "sourcing.sh")
+	assert.NilError(t, os.WriteFile(sourcingFile, []byte("source bin/hello\necho sourced\n"), 0o600))
+	command := exec.Command("bash", sourcingFile)
+	command.Dir = rootDirectory
+	output, err = command.CombinedOutput()
+	assert.NilError(t, err, string(output))
+	assert.Equal(t, "sourced\n", string(output))
+}
+
+func TestToCamelCase(t *testing.T) {
+	assert.Equal(t, "hello", toCamelCase("hello"))
+	assert.Equal(t, "myBinaryName", toCamelCase("my-binary_name"))
+}
@@ -0,0 +1,46 @@
+// Package diffhelper allowing to compute differences between contents
+package diffhelper
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	contextLinesCount    = 3
+	noNewLineAtEndOfFile = "\n\\ No newline at end of file\n"
+)
+
+// UnifiedDiff returns the unified diff between fromContent and toContent
+// or an empty string if contents are identical
+func UnifiedDiff(fromFile string, toFile string, fromContent string, toContent string) (string, error) {
+	if fromContent == toContent {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(fromContent),
+		B:        splitLines(toContent),
+		FromFile: fromFile,
+		FromDate: "",
+		ToFile:   toFile,
+		ToDate:   "",
+		Eol:      "\n",
+		Context:  contextLinesCount,
+	})
+}
+
+// splitLines splits content keeping line endings, the last line
+// without new line is marked as patch tools expect
+func splitLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	lines := strings.SplitAfter(content, "\n")
+	lastLine := lines[len(lines)-1]
+	if lastLine == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] = lastLine + noNewLineAtEndOfFile
+	return lines
+}
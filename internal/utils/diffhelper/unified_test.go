@@ -0,0 +1,50 @@
+package diffhelper
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name        string
+		fromContent string
+		toContent   string
+		expected    string
+	}{
+		{
+			name:        "identical",
+			fromContent: "line1\nline2\n",
+			toContent:   "line1\nline2\n",
+			expected:    "",
+		},
+		{
+			name:        "new file",
+			fromContent: "",
+			toContent:   "line1\nline2\n",
+			expected:    "--- a/file\n+++ b/file\n@@ -0,0 +1,2 @@\n+line1\n+line2\n",
+		},
+		{
+			name:        "line changed",
+			fromContent: "line1\nline2\nline3\nline4\nline5\nline6\n",
+			toContent:   "line1\nline2\nline3\nline4\nline5 changed\nline6\n",
+			expected: "--- a/file\n+++ b/file\n@@ -2,5 +2,5 @@\n line2\n line3\n line4\n" +
+				"-line5\n+line5 changed\n line6\n",
+		},
+		{
+			name:        "no new line at end of file",
+			fromContent: "line1\nline2",
+			toContent:   "line1\nline2\n",
+			expected: "--- a/file\n+++ b/file\n@@ -1,2 +1,2 @@\n line1\n" +
+				"-line2\n\\ No newline at end of file\n+line2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := UnifiedDiff("a/file", "b/file", tt.fromContent, tt.toContent)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.expected, diff)
+		})
+	}
+}